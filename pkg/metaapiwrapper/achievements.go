@@ -0,0 +1,220 @@
+package metaapiwrapper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+//https://developer.oculus.com/documentation/unity/ps-achievements-s2s/
+
+// ErrInvalidAchievementWrite is returned when a WriteAchievementQuery does not set exactly one of ForceUnlock, AddCount and AddFields
+var ErrInvalidAchievementWrite = errors.New("metaapiwrapper: achievement write needs exactly one of force_unlock, add_count, add_fields")
+
+const (
+	AchievementTypeSimple   = "SIMPLE"
+	AchievementTypeCount    = "COUNT"
+	AchievementTypeBitfield = "BITFIELD"
+)
+
+// jsonArrayParam renders list parameters such as api_names the way Graph expects them, a JSON array
+func jsonArrayParam(values []string) string {
+	encoded, _ := json.Marshal(values)
+	return string(encoded)
+}
+
+type AchievementDefinitionsQuery struct {
+	APINames        []string `json:"api_names"`
+	Fields          []string `json:"fields"`
+	IncludeArchived bool     `json:"include_archived"`
+	After           string   `json:"after,omitempty"`
+}
+
+func (a *AchievementDefinitionsQuery) BuildQuery(cfg OCULUSPlatformConfig) url.Values {
+	params := url.Values{}
+	params.Add("access_token", cfg.FormAccessToken())
+	if len(a.APINames) > 0 {
+		params.Add("api_names", jsonArrayParam(a.APINames))
+	}
+	if len(a.Fields) > 0 {
+		params.Add("fields", strings.Join(a.Fields, ","))
+	}
+	if a.IncludeArchived {
+		params.Add("include_archived", "true")
+	}
+	if len(a.After) > 0 {
+		params.Add("after", a.After)
+	}
+
+	return params
+}
+
+type AchievementDefinition struct {
+	ID                          string `json:"id"`
+	APIName                     string `json:"api_name"`
+	AchievementType             string `json:"achievement_type"`
+	AchievementWritePolicy      string `json:"achievement_write_policy"`
+	Target                      int64  `json:"target"`
+	BitfieldLength              int    `json:"bitfield_length"`
+	IsArchived                  bool   `json:"is_archived"`
+	IsSecret                    bool   `json:"is_secret"`
+	Title                       string `json:"title"`
+	Description                 string `json:"description"`
+	UnlockedDescriptionOverride string `json:"unlocked_description_override"`
+	LockedImageURI              string `json:"locked_image_uri"`
+	UnlockedImageURI            string `json:"unlocked_image_uri"`
+}
+
+type AchievementDefinitionsResponse struct {
+	Data   []AchievementDefinition `json:"data"`
+	Paging OculusPaging            `json:"paging"`
+}
+
+func (m *metaApiRepositoryImpl) RequestOculusAchievementDefinitions(q AchievementDefinitionsQuery) (oculusResp AchievementDefinitionsResponse, err error) {
+	return m.RequestOculusAchievementDefinitionsCtx(context.Background(), q)
+}
+
+// RequestOculusAchievementDefinitionsCtx lists the achievement definitions of the app, all of them when q.APINames is empty
+func (m *metaApiRepositoryImpl) RequestOculusAchievementDefinitionsCtx(ctx context.Context, q AchievementDefinitionsQuery) (oculusResp AchievementDefinitionsResponse, err error) {
+	var req *http.Request
+
+	if req, err = m.newEndpointRequest(ctx, EndpointAchievementDefinitions, "", q.BuildQuery(m.AccessToken)); err != nil {
+		return
+	}
+
+	// Send request
+	err = m.call(EndpointAchievementDefinitions, req, &oculusResp, true)
+
+	return
+}
+
+type UserAchievementsQuery struct {
+	UserID   string   `json:"user_id"`
+	APINames []string `json:"api_names"`
+	Fields   []string `json:"fields"`
+	After    string   `json:"after,omitempty"`
+}
+
+func (u *UserAchievementsQuery) BuildQuery(cfg OCULUSPlatformConfig) url.Values {
+	params := url.Values{}
+	params.Add("access_token", cfg.FormAccessToken())
+	if len(u.APINames) > 0 {
+		params.Add("api_names", jsonArrayParam(u.APINames))
+	}
+	if len(u.Fields) > 0 {
+		params.Add("fields", strings.Join(u.Fields, ","))
+	}
+	if len(u.After) > 0 {
+		params.Add("after", u.After)
+	}
+
+	return params
+}
+
+type UserAchievement struct {
+	ID               string                `json:"id"`
+	Definition       AchievementDefinition `json:"definition"`
+	CountProgress    int64                 `json:"count_progress"`
+	BitfieldProgress string                `json:"bitfield_progress"`
+	IsUnlocked       bool                  `json:"is_unlocked"`
+	UnlockTime       int64                 `json:"unlock_time"`
+}
+
+type UserAchievementsResponse struct {
+	Data   []UserAchievement `json:"data"`
+	Paging OculusPaging      `json:"paging"`
+}
+
+func (m *metaApiRepositoryImpl) RequestOculusUserAchievements(q UserAchievementsQuery) (oculusResp UserAchievementsResponse, err error) {
+	return m.RequestOculusUserAchievementsCtx(context.Background(), q)
+}
+
+// RequestOculusUserAchievementsCtx queries the progress of q.UserID
+func (m *metaApiRepositoryImpl) RequestOculusUserAchievementsCtx(ctx context.Context, q UserAchievementsQuery) (oculusResp UserAchievementsResponse, err error) {
+	var req *http.Request
+
+	if req, err = m.newEndpointRequest(ctx, EndpointUserAchievements, q.UserID, q.BuildQuery(m.AccessToken)); err != nil {
+		return
+	}
+
+	// Send request
+	err = m.call(EndpointUserAchievements, req, &oculusResp, true)
+
+	return
+}
+
+// WriteAchievementQuery unlocks (ForceUnlock), adds to a COUNT achievement (AddCount)
+// or sets fields of a BITFIELD achievement (AddFields, e.g. "1001"); exactly one must be set
+type WriteAchievementQuery struct {
+	UserID      string `json:"user_id"`
+	APIName     string `json:"api_name"`
+	ForceUnlock bool   `json:"force_unlock,omitempty"`
+	AddCount    int64  `json:"add_count,omitempty"`
+	AddFields   string `json:"add_fields,omitempty"`
+}
+
+func (w *WriteAchievementQuery) Validate() error {
+	modes := 0
+	if w.ForceUnlock {
+		modes++
+	}
+	if w.AddCount != 0 {
+		modes++
+	}
+	if len(w.AddFields) > 0 {
+		modes++
+	}
+
+	if modes != 1 || len(w.UserID) <= 0 || len(w.APIName) <= 0 {
+		return ErrInvalidAchievementWrite
+	}
+	return nil
+}
+
+func (w *WriteAchievementQuery) BuildQuery(cfg OCULUSPlatformConfig) url.Values {
+	params := url.Values{}
+	params.Add("access_token", cfg.FormAccessToken())
+	params.Add("api_name", w.APIName)
+	switch {
+	case w.ForceUnlock:
+		params.Add("force_unlock", "true")
+	case w.AddCount != 0:
+		params.Add("add_count", strconv.FormatInt(w.AddCount, 10))
+	case len(w.AddFields) > 0:
+		params.Add("add_fields", w.AddFields)
+	}
+
+	return params
+}
+
+type WriteAchievementResponse struct {
+	ID           string `json:"id"`
+	APIName      string `json:"api_name"`
+	JustUnlocked bool   `json:"just_unlocked"`
+}
+
+func (m *metaApiRepositoryImpl) RequestOculusWriteAchievement(q WriteAchievementQuery) (oculusResp WriteAchievementResponse, err error) {
+	return m.RequestOculusWriteAchievementCtx(context.Background(), q)
+}
+
+// RequestOculusWriteAchievementCtx writes progress for q.UserID; writes are not retried since AddCount is not idempotent
+func (m *metaApiRepositoryImpl) RequestOculusWriteAchievementCtx(ctx context.Context, q WriteAchievementQuery) (oculusResp WriteAchievementResponse, err error) {
+	if err = q.Validate(); err != nil {
+		return
+	}
+
+	var req *http.Request
+
+	if req, err = m.newEndpointRequest(ctx, EndpointWriteAchievement, q.UserID, q.BuildQuery(m.AccessToken)); err != nil {
+		return
+	}
+
+	// Send request
+	err = m.call(EndpointWriteAchievement, req, &oculusResp, false)
+
+	return
+}
@@ -0,0 +1,197 @@
+package metaapiwrapper_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/hyperbting/api-library/pkg/metaapiwrapper"
+)
+
+// achievementServer answers every request with body and keeps the last one
+type achievementServer struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	calls  int
+	method string
+	path   string
+	query  url.Values
+}
+
+func newAchievementServer(body string) *achievementServer {
+	s := &achievementServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.calls++
+		s.method, s.path, s.query = r.Method, r.URL.Path, r.URL.Query()
+		s.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	return s
+}
+
+func (s *achievementServer) repository() metaapiwrapper.MetaApiRepository {
+	return metaapiwrapper.NewMetaApiRepository(
+		metaapiwrapper.WithBaseURL(s.URL),
+		metaapiwrapper.WithPlatformConfig(metaapiwrapper.OCULUSPlatformConfig{AppID: "app", AppSecret: "secret"}),
+	)
+}
+
+func TestWriteAchievement(t *testing.T) {
+	tests := []struct {
+		name      string
+		q         metaapiwrapper.WriteAchievementQuery
+		param     string
+		value     string
+		unwritten []string
+	}{
+		{
+			name:      "unlock",
+			q:         metaapiwrapper.WriteAchievementQuery{UserID: "u1", APIName: "first_win", ForceUnlock: true},
+			param:     "force_unlock",
+			value:     "true",
+			unwritten: []string{"add_count", "add_fields"},
+		},
+		{
+			name:      "add count",
+			q:         metaapiwrapper.WriteAchievementQuery{UserID: "u1", APIName: "wins", AddCount: 3},
+			param:     "add_count",
+			value:     "3",
+			unwritten: []string{"force_unlock", "add_fields"},
+		},
+		{
+			name:      "add fields",
+			q:         metaapiwrapper.WriteAchievementQuery{UserID: "u1", APIName: "maps", AddFields: "1001"},
+			param:     "add_fields",
+			value:     "1001",
+			unwritten: []string{"force_unlock", "add_count"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newAchievementServer(`{"id":"1","api_name":"` + tt.q.APIName + `","just_unlocked":true}`)
+			defer s.Close()
+
+			resp, err := s.repository().RequestOculusWriteAchievementCtx(context.Background(), tt.q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.APIName != tt.q.APIName || !resp.JustUnlocked {
+				t.Errorf("response %+v", resp)
+			}
+
+			if s.method != http.MethodPost || s.path != "/u1/achievements" {
+				t.Errorf("%v %v, want POST /u1/achievements", s.method, s.path)
+			}
+			if got := s.query.Get("api_name"); got != tt.q.APIName {
+				t.Errorf("api_name %q, want %q", got, tt.q.APIName)
+			}
+			if got := s.query.Get(tt.param); got != tt.value {
+				t.Errorf("%v %q, want %q", tt.param, got, tt.value)
+			}
+			for _, param := range tt.unwritten {
+				if s.query.Has(param) {
+					t.Errorf("%v sent along with %v", param, tt.param)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteAchievementValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		q     metaapiwrapper.WriteAchievementQuery
+		valid bool
+	}{
+		{name: "unlock", q: metaapiwrapper.WriteAchievementQuery{UserID: "u1", APIName: "a", ForceUnlock: true}, valid: true},
+		{name: "add count", q: metaapiwrapper.WriteAchievementQuery{UserID: "u1", APIName: "a", AddCount: 1}, valid: true},
+		{name: "negative count", q: metaapiwrapper.WriteAchievementQuery{UserID: "u1", APIName: "a", AddCount: -1}, valid: true},
+		{name: "add fields", q: metaapiwrapper.WriteAchievementQuery{UserID: "u1", APIName: "a", AddFields: "01"}, valid: true},
+		{name: "no mode", q: metaapiwrapper.WriteAchievementQuery{UserID: "u1", APIName: "a"}},
+		{name: "unlock and count", q: metaapiwrapper.WriteAchievementQuery{UserID: "u1", APIName: "a", ForceUnlock: true, AddCount: 1}},
+		{name: "count and fields", q: metaapiwrapper.WriteAchievementQuery{UserID: "u1", APIName: "a", AddCount: 1, AddFields: "1"}},
+		{name: "all modes", q: metaapiwrapper.WriteAchievementQuery{UserID: "u1", APIName: "a", ForceUnlock: true, AddCount: 1, AddFields: "1"}},
+		{name: "no user", q: metaapiwrapper.WriteAchievementQuery{APIName: "a", ForceUnlock: true}},
+		{name: "no api name", q: metaapiwrapper.WriteAchievementQuery{UserID: "u1", ForceUnlock: true}},
+	}
+
+	s := newAchievementServer(`{}`)
+	defer s.Close()
+	repo := s.repository()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.q.Validate()
+			if tt.valid {
+				if err != nil {
+					t.Errorf("got %v, want valid", err)
+				}
+				return
+			}
+			if !errors.Is(err, metaapiwrapper.ErrInvalidAchievementWrite) {
+				t.Fatalf("got %v, want ErrInvalidAchievementWrite", err)
+			}
+
+			// an invalid write never reaches Graph
+			calls := s.calls
+			if _, err := repo.RequestOculusWriteAchievementCtx(context.Background(), tt.q); !errors.Is(err, metaapiwrapper.ErrInvalidAchievementWrite) {
+				t.Errorf("write: got %v, want ErrInvalidAchievementWrite", err)
+			}
+			if s.calls != calls {
+				t.Error("the invalid write was sent")
+			}
+		})
+	}
+}
+
+func TestAchievementAPINames(t *testing.T) {
+	s := newAchievementServer(`{"data":[]}`)
+	defer s.Close()
+	repo := s.repository()
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		apiNames []string
+		want     string
+	}{
+		{name: "one", apiNames: []string{"first_win"}, want: `["first_win"]`},
+		{name: "several", apiNames: []string{"first_win", "wins"}, want: `["first_win","wins"]`},
+		{name: "quotes and commas", apiNames: []string{`a"b`, "c,d"}, want: `["a\"b","c,d"]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := repo.RequestOculusAchievementDefinitionsCtx(ctx, metaapiwrapper.AchievementDefinitionsQuery{APINames: tt.apiNames}); err != nil {
+				t.Fatal(err)
+			}
+			if got := s.query.Get("api_names"); got != tt.want || s.path != "/app/achievement_definitions" {
+				t.Errorf("definitions: %v api_names %v, want %v", s.path, got, tt.want)
+			}
+
+			if _, err := repo.RequestOculusUserAchievementsCtx(ctx, metaapiwrapper.UserAchievementsQuery{UserID: "u1", APINames: tt.apiNames}); err != nil {
+				t.Fatal(err)
+			}
+			if got := s.query.Get("api_names"); got != tt.want || s.path != "/u1/achievements" {
+				t.Errorf("user achievements: %v api_names %v, want %v", s.path, got, tt.want)
+			}
+		})
+	}
+
+	// without names the parameter is left out and Graph lists every achievement
+	if _, err := repo.RequestOculusAchievementDefinitionsCtx(ctx, metaapiwrapper.AchievementDefinitionsQuery{}); err != nil {
+		t.Fatal(err)
+	}
+	if s.query.Has("api_names") {
+		t.Errorf("empty api_names sent as %q", s.query.Get("api_names"))
+	}
+}
@@ -0,0 +1,179 @@
+package metaapiwrapper
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+const defaultBatchConcurrency = 4
+
+// OwnershipResult is the outcome for one SKU of a batch; Err is nil when ownership could be decided
+type OwnershipResult struct {
+	Owned bool
+	Err   error
+}
+
+// BatchOptions tunes VerifyOwnershipBatch; the zero value is usable
+type BatchOptions struct {
+	// Concurrency bounds the Graph calls in flight for one batch, 4 when 0
+	Concurrency int
+	// PurchasesThreshold switches a user to a single viewer_purchases walk once it has that many SKUs to check; 0 never does.
+	// viewer_purchases takes the org scoped ID, which Resolver finds for the app scoped IDs of the batch
+	PurchasesThreshold int
+	// Resolver maps app scoped to org scoped IDs for PurchasesThreshold, a resolver that only remembers one batch when nil
+	Resolver *ScopedIDResolver
+}
+
+// WithBatchOptions tunes VerifyOwnershipBatch and VerifyOwnershipBatchUsers
+func WithBatchOptions(opts BatchOptions) Option {
+	return func(m *metaApiRepositoryImpl) {
+		m.batch = opts
+	}
+}
+
+// batchConfigured lets wrappers such as CachedMetaApiRepository reuse the options of the repository they wrap
+type batchConfigured interface {
+	batchOptions() BatchOptions
+}
+
+func (m *metaApiRepositoryImpl) batchOptions() BatchOptions {
+	return m.batch
+}
+
+func (m *metaApiRepositoryImpl) VerifyOwnershipBatch(ctx context.Context, userID string, skus []string) map[string]OwnershipResult {
+	return verifyOwnershipBatchUsers(ctx, m, []string{userID}, skus, m.batch)[userID]
+}
+
+func (m *metaApiRepositoryImpl) VerifyOwnershipBatchUsers(ctx context.Context, userIDs []string, skus []string) map[string]map[string]OwnershipResult {
+	return verifyOwnershipBatchUsers(ctx, m, userIDs, skus, m.batch)
+}
+
+func (c *CachedMetaApiRepository) batchOptions() (opts BatchOptions) {
+	if inner, ok := c.MetaApiRepository.(batchConfigured); ok {
+		opts = inner.batchOptions()
+	}
+	return
+}
+
+// VerifyOwnershipBatch checks every SKU through the cache
+func (c *CachedMetaApiRepository) VerifyOwnershipBatch(ctx context.Context, userID string, skus []string) map[string]OwnershipResult {
+	return verifyOwnershipBatchUsers(ctx, c, []string{userID}, skus, c.batchOptions())[userID]
+}
+
+func (c *CachedMetaApiRepository) VerifyOwnershipBatchUsers(ctx context.Context, userIDs []string, skus []string) map[string]map[string]OwnershipResult {
+	return verifyOwnershipBatchUsers(ctx, c, userIDs, skus, c.batchOptions())
+}
+
+// verifyOwnershipBatchUsers fans out over users and SKUs with at most opts.Concurrency calls in flight
+func verifyOwnershipBatchUsers(ctx context.Context, repo MetaApiRepository, userIDs []string, skus []string, opts BatchOptions) map[string]map[string]OwnershipResult {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+
+	skus = slices.Compact(slices.Sorted(slices.Values(skus)))
+	usePurchases := opts.PurchasesThreshold > 0 && len(skus) >= opts.PurchasesThreshold
+	resolver := opts.Resolver
+	if usePurchases && resolver == nil {
+		resolver = NewScopedIDResolver(repo, nil)
+	}
+
+	results := make(map[string]map[string]OwnershipResult, len(userIDs))
+	for _, userID := range userIDs {
+		results[userID] = make(map[string]OwnershipResult, len(skus))
+	}
+
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, concurrency)
+	)
+	set := func(userID, sku string, res OwnershipResult) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		results[userID][sku] = res
+	}
+	run := func(job func(), onCancel func(err error)) {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			onCancel(ctx.Err())
+			return
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			job()
+		}()
+	}
+
+	for _, userID := range userIDs {
+		if usePurchases {
+			run(func() {
+				for sku, res := range ownershipFromPurchases(ctx, repo, resolver, userID, skus) {
+					set(userID, sku, res)
+				}
+			}, func(err error) {
+				for _, sku := range skus {
+					set(userID, sku, OwnershipResult{Err: err})
+				}
+			})
+			continue
+		}
+
+		for _, sku := range skus {
+			run(func() {
+				_, err := repo.RequestOculusVerifyItemOwnershipCtx(ctx, VerifyItemOwnershipQuery{UsrID: userID, SKU: sku})
+				set(userID, sku, ownershipResult(err))
+			}, func(err error) {
+				set(userID, sku, OwnershipResult{Err: err})
+			})
+		}
+	}
+
+	wg.Wait()
+	return results
+}
+
+// ownershipResult maps ErrEntitlementMissing to a decided "not owned"
+func ownershipResult(err error) OwnershipResult {
+	switch {
+	case err == nil:
+		return OwnershipResult{Owned: true}
+	case errors.Is(err, ErrEntitlementMissing):
+		return OwnershipResult{}
+	default:
+		return OwnershipResult{Err: err}
+	}
+}
+
+func ownershipFromPurchases(ctx context.Context, repo MetaApiRepository, resolver *ScopedIDResolver, userID string, skus []string) map[string]OwnershipResult {
+	results := make(map[string]OwnershipResult, len(skus))
+
+	orgScopedID, err := resolver.Resolve(ctx, AppScopedID(userID))
+	var items []OculusData
+	if err == nil {
+		items, err = repo.AllItemsOwned(ctx, RetrieveItemsOwnedQuery{
+			OrgScopedID: string(orgScopedID),
+			Fields:      Fields(PurchaseFieldGrantTime, PurchaseFieldExpirationTime, PurchaseItem(ItemFieldSKU)),
+		})
+	}
+
+	// expired and not yet granted purchases are listed too; decide like verify_entitlement does
+	owned := NewEntitlementSet(items, nil)
+	now := time.Now()
+	for _, sku := range skus {
+		if err != nil {
+			results[sku] = OwnershipResult{Err: err}
+			continue
+		}
+		results[sku] = OwnershipResult{Owned: owned.Has(sku, now)}
+	}
+	return results
+}
@@ -0,0 +1,147 @@
+package metaapiwrapper_test
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperbting/api-library/pkg/metaapiwrapper"
+	"github.com/hyperbting/api-library/pkg/metaapiwrapper/metaapitest"
+)
+
+func TestVerifyOwnershipBatch(t *testing.T) {
+	s := metaapitest.NewServer("app", "secret")
+	defer s.Close()
+	s.AddEntitlement("u1", metaapitest.Entitlement{SKU: "a"})
+
+	repo := metaapiwrapper.NewMetaApiRepository(s.Options()...)
+
+	got := repo.VerifyOwnershipBatch(context.Background(), "u1", []string{"a", "b", "a"})
+	if len(got) != 2 || !got["a"].Owned || got["a"].Err != nil || got["b"].Owned || got["b"].Err != nil {
+		t.Errorf("got %+v", got)
+	}
+	if n := s.Calls(metaapitest.EndpointVerifyEntitlement); n != 2 {
+		t.Errorf("%v verify calls, want 2", n)
+	}
+}
+
+func TestVerifyOwnershipBatchErrors(t *testing.T) {
+	s := metaapitest.NewServer("app", "secret")
+	defer s.Close()
+	s.InjectError(metaapitest.EndpointVerifyEntitlement, metaapiwrapper.MetaAPIError{StatusCode: http.StatusInternalServerError, Code: 2, Message: "down"}, 0)
+
+	repo := metaapiwrapper.NewMetaApiRepository(s.Options()...)
+
+	// an undecided SKU must not read as "not owned"
+	for sku, res := range repo.VerifyOwnershipBatch(context.Background(), "u1", []string{"a", "b"}) {
+		if res.Owned || !metaapiwrapper.IsTransient(res.Err) {
+			t.Errorf("%v: got %+v, want the transient error", sku, res)
+		}
+	}
+}
+
+func TestVerifyOwnershipBatchCancelled(t *testing.T) {
+	s := metaapitest.NewServer("app", "secret")
+	defer s.Close()
+
+	repo := metaapiwrapper.NewMetaApiRepository(s.Options()...)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	got := repo.VerifyOwnershipBatch(ctx, "u1", []string{"a", "b"})
+	if len(got) != 2 {
+		t.Fatalf("got %+v, want a result per SKU", got)
+	}
+	for sku, res := range got {
+		if res.Err == nil {
+			t.Errorf("%v: got %+v, want an error", sku, res)
+		}
+	}
+}
+
+// purchasesUserIDs records the user_id of every viewer_purchases request
+type purchasesUserIDs struct {
+	next http.RoundTripper
+
+	mu  sync.Mutex
+	ids []string
+}
+
+func (t *purchasesUserIDs) RoundTrip(req *http.Request) (*http.Response, error) {
+	if strings.HasSuffix(req.URL.Path, "/viewer_purchases") {
+		t.mu.Lock()
+		t.ids = append(t.ids, req.URL.Query().Get("user_id"))
+		t.mu.Unlock()
+	}
+	return t.next.RoundTrip(req)
+}
+
+func TestVerifyOwnershipBatchUsersPurchases(t *testing.T) {
+	s := metaapitest.NewServer("app", "secret")
+	defer s.Close()
+	s.AddUser(metaapitest.User{ID: "u1", OrgScopedID: "org1"})
+	s.AddUser(metaapitest.User{ID: "u2", OrgScopedID: "org2"})
+	s.AddEntitlement("u1", metaapitest.Entitlement{SKU: "a"})
+	s.AddEntitlement("u1", metaapitest.Entitlement{SKU: "c", ExpirationTime: time.Now().Add(-time.Hour).Unix()})
+	s.AddEntitlement("u2", metaapitest.Entitlement{SKU: "b"})
+	s.AddEntitlement("u2", metaapitest.Entitlement{SKU: "c", GrantTime: time.Now().Add(time.Hour).Unix()})
+
+	transport := &purchasesUserIDs{next: s.Client().Transport}
+	repo := metaapiwrapper.NewMetaApiRepository(append(s.Options(),
+		metaapiwrapper.WithRoundTripper(transport),
+		metaapiwrapper.WithBatchOptions(metaapiwrapper.BatchOptions{PurchasesThreshold: 2}))...)
+
+	// the cache wrapper follows the options of the repository it wraps
+	for name, r := range map[string]metaapiwrapper.MetaApiRepository{
+		"repository": repo,
+		"cached":     metaapiwrapper.NewCachedMetaApiRepository(repo, metaapiwrapper.CacheConfig{}),
+	} {
+		got := r.VerifyOwnershipBatchUsers(context.Background(), []string{"u1", "u2"}, []string{"a", "b", "c"})
+		want := map[string]map[string]bool{
+			"u1": {"a": true, "b": false, "c": false},
+			"u2": {"a": false, "b": true, "c": false},
+		}
+		for userID, skus := range want {
+			for sku, owned := range skus {
+				if res := got[userID][sku]; res.Err != nil || res.Owned != owned {
+					t.Errorf("%v: %v %v got %+v, want owned %v", name, userID, sku, res, owned)
+				}
+			}
+		}
+	}
+
+	if n := s.Calls(metaapitest.EndpointVerifyEntitlement); n != 0 {
+		t.Errorf("%v verify calls, want 0", n)
+	}
+	if n := s.Calls(metaapitest.EndpointViewerPurchases); n != 4 {
+		t.Errorf("%v purchases calls, want one per user and repository", n)
+	}
+
+	// viewer_purchases is asked with the org scoped IDs, not the app scoped ones of the batch
+	slices.Sort(transport.ids)
+	if want := []string{"org1", "org1", "org2", "org2"}; !slices.Equal(transport.ids, want) {
+		t.Errorf("viewer_purchases user IDs %v, want %v", transport.ids, want)
+	}
+}
+
+func TestVerifyOwnershipBatchPurchasesResolveError(t *testing.T) {
+	s := metaapitest.NewServer("app", "secret")
+	defer s.Close()
+	s.AddUser(metaapitest.User{ID: "u1", OrgScopedID: "org1"})
+	s.AddEntitlement("u1", metaapitest.Entitlement{SKU: "a"})
+	s.InjectError(metaapitest.EndpointOrgScopedID, metaapiwrapper.MetaAPIError{StatusCode: http.StatusBadRequest, Code: 100, Message: "bad user"}, 0)
+
+	repo := metaapiwrapper.NewMetaApiRepository(append(s.Options(), metaapiwrapper.WithBatchOptions(metaapiwrapper.BatchOptions{PurchasesThreshold: 1}))...)
+
+	got := repo.VerifyOwnershipBatch(context.Background(), "u1", []string{"a"})
+	if got["a"].Err == nil || got["a"].Owned {
+		t.Errorf("got %+v, want the resolve error", got["a"])
+	}
+	if n := s.Calls(metaapitest.EndpointViewerPurchases); n != 0 {
+		t.Errorf("%v purchases calls, want 0", n)
+	}
+}
@@ -0,0 +1,223 @@
+package metaapiwrapper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBreakerFailureThreshold = 5
+	defaultBreakerCoolDown         = 30 * time.Second
+
+	lastKnownCacheKeyPrefix = "lastknown|"
+)
+
+// ErrCircuitOpen is returned without calling Graph while the breaker of the endpoint is open
+var ErrCircuitOpen = errors.New("metaapiwrapper: circuit open")
+
+type CircuitState int
+
+const (
+	CircuitClosed CircuitState = iota
+	CircuitOpen
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("CircuitState(%d)", int(s))
+	}
+}
+
+// BreakerConfig tunes the per endpoint circuit breakers; the zero value is usable
+type BreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures opening the circuit, 5 when 0
+	FailureThreshold int
+	// CoolDown keeps the circuit open before a single probe call is let through, 30 seconds when 0
+	CoolDown time.Duration
+	// IsFailure decides which errors count against the endpoint; by default transient Graph errors,
+	// transport errors and deadlines do, while rejections such as a missing entitlement and cancellations do not
+	IsFailure func(err error) bool
+	// OnStateChange is called outside of any lock whenever an endpoint changes state
+	OnStateChange func(endpoint string, from, to CircuitState)
+}
+
+// WithCircuitBreaker stops calling an endpoint that keeps failing, answering ErrCircuitOpen until its cool-down ends
+func WithCircuitBreaker(cfg BreakerConfig) Option {
+	return func(m *metaApiRepositoryImpl) {
+		m.breakers = newCircuitBreakers(cfg)
+	}
+}
+
+// breakerFailure is the default BreakerConfig.IsFailure
+func breakerFailure(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, ErrRateLimited) {
+		return false
+	}
+
+	var apiErr *MetaAPIError
+	if errors.As(err, &apiErr) {
+		return IsTransient(err)
+	}
+
+	var urlErr *url.Error
+	return errors.As(err, &urlErr) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// circuitBreakers holds one breaker per endpoint name
+type circuitBreakers struct {
+	cfg BreakerConfig
+
+	mu        sync.Mutex
+	endpoints map[string]*circuitBreaker
+}
+
+type circuitBreaker struct {
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func newCircuitBreakers(cfg BreakerConfig) *circuitBreakers {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = defaultBreakerFailureThreshold
+	}
+	if cfg.CoolDown <= 0 {
+		cfg.CoolDown = defaultBreakerCoolDown
+	}
+	if cfg.IsFailure == nil {
+		cfg.IsFailure = breakerFailure
+	}
+
+	return &circuitBreakers{
+		cfg:       cfg,
+		endpoints: map[string]*circuitBreaker{},
+	}
+}
+
+// allow admits a call to endpoint; report must be called with its outcome
+func (c *circuitBreakers) allow(endpoint string) (report func(err error), err error) {
+	c.mu.Lock()
+	b, ok := c.endpoints[endpoint]
+	if !ok {
+		b = &circuitBreaker{}
+		c.endpoints[endpoint] = b
+	}
+
+	from := b.state
+	if b.state == CircuitOpen && time.Since(b.openedAt) >= c.cfg.CoolDown {
+		b.state = CircuitHalfOpen
+	}
+
+	to := b.state
+
+	if b.state == CircuitOpen || (b.state == CircuitHalfOpen && b.probing) {
+		c.mu.Unlock()
+		c.changed(endpoint, from, to)
+		return nil, ErrCircuitOpen
+	}
+
+	probe := b.state == CircuitHalfOpen
+	if probe {
+		b.probing = true
+	}
+	c.mu.Unlock()
+	c.changed(endpoint, from, to)
+
+	return func(err error) {
+		c.report(endpoint, b, probe, err)
+	}, nil
+}
+
+func (c *circuitBreakers) report(endpoint string, b *circuitBreaker, probe bool, err error) {
+	failed := err != nil && c.cfg.IsFailure(err)
+
+	c.mu.Lock()
+	from := b.state
+	if probe {
+		b.probing = false
+	}
+
+	// errors that are not failures, e.g. a cancelled call, change nothing; a cancelled probe leaves the circuit half-open for the next caller
+	switch {
+	case failed:
+		b.failures++
+		if probe || (b.state == CircuitClosed && b.failures >= c.cfg.FailureThreshold) {
+			b.state = CircuitOpen
+			b.openedAt = time.Now()
+		}
+	case err == nil:
+		b.state = CircuitClosed
+		b.failures = 0
+	}
+	to := b.state
+	c.mu.Unlock()
+
+	c.changed(endpoint, from, to)
+}
+
+func (c *circuitBreakers) changed(endpoint string, from, to CircuitState) {
+	if from != to && c.cfg.OnStateChange != nil {
+		c.cfg.OnStateChange(endpoint, from, to)
+	}
+}
+
+// OwnershipFallback remembers ownership answers so that they can be served while verify_entitlement is unavailable
+type OwnershipFallback interface {
+	Remember(userID, sku string, owned bool)
+	Recall(userID, sku string) (owned bool, ok bool)
+}
+
+// WithOwnershipFallback answers RequestOculusVerifyItemOwnership from fallback while its circuit is open
+func WithOwnershipFallback(fallback OwnershipFallback) Option {
+	return func(m *metaApiRepositoryImpl) {
+		m.ownershipFallback = fallback
+	}
+}
+
+// LastKnownOwnership is an OwnershipFallback serving the last answer Graph gave, for up to a TTL
+type LastKnownOwnership struct {
+	backend EntitlementCache
+	ttl     time.Duration
+}
+
+// NewLastKnownOwnership keeps answers in backend (an in-memory LRU when nil) for ttl
+func NewLastKnownOwnership(backend EntitlementCache, ttl time.Duration) *LastKnownOwnership {
+	if backend == nil {
+		backend = NewLRUEntitlementCache(defaultLRUCacheSize)
+	}
+
+	return &LastKnownOwnership{
+		backend: backend,
+		ttl:     ttl,
+	}
+}
+
+func lastKnownCacheKey(userID, sku string) string {
+	return fmt.Sprintf("%v%v|%v", lastKnownCacheKeyPrefix, userID, sku)
+}
+
+func (l *LastKnownOwnership) Remember(userID, sku string, owned bool) {
+	value := []byte("0")
+	if owned {
+		value = []byte("1")
+	}
+	l.backend.Set(lastKnownCacheKey(userID, sku), value, l.ttl)
+}
+
+func (l *LastKnownOwnership) Recall(userID, sku string) (owned bool, ok bool) {
+	value, ok := l.backend.Get(lastKnownCacheKey(userID, sku))
+	return ok && string(value) == "1", ok
+}
@@ -0,0 +1,181 @@
+package metaapiwrapper_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperbting/api-library/pkg/metaapiwrapper"
+	"github.com/hyperbting/api-library/pkg/metaapiwrapper/metaapitest"
+)
+
+var graphDown = metaapiwrapper.MetaAPIError{StatusCode: http.StatusServiceUnavailable, Code: 2, Message: "down"}
+
+// stateLog collects BreakerConfig.OnStateChange calls
+type stateLog struct {
+	mu      sync.Mutex
+	changes []string
+}
+
+func (l *stateLog) record(endpoint string, from, to metaapiwrapper.CircuitState) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.changes = append(l.changes, fmt.Sprintf("%v %v>%v", endpoint, from, to))
+}
+
+func (l *stateLog) get() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return slices.Clone(l.changes)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	s := metaapitest.NewServer("app", "secret")
+	defer s.Close()
+	s.AddEntitlement("u1", metaapitest.Entitlement{SKU: "sku"})
+	s.InjectError(metaapitest.EndpointVerifyEntitlement, graphDown, 0)
+
+	var log stateLog
+	coolDown := 20 * time.Millisecond
+	repo := metaapiwrapper.NewMetaApiRepository(append(s.Options(), metaapiwrapper.WithCircuitBreaker(metaapiwrapper.BreakerConfig{
+		FailureThreshold: 2,
+		CoolDown:         coolDown,
+		OnStateChange:    log.record,
+	}))...)
+	ctx := context.Background()
+	verify := func() error {
+		_, err := repo.RequestOculusVerifyItemOwnershipCtx(ctx, metaapiwrapper.VerifyItemOwnershipQuery{UsrID: "u1", SKU: "sku"})
+		return err
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := verify(); !metaapiwrapper.IsTransient(err) {
+			t.Fatalf("call %v: got %v, want the transient error", i, err)
+		}
+	}
+	if err := verify(); !errors.Is(err, metaapiwrapper.ErrCircuitOpen) {
+		t.Fatalf("got %v, want ErrCircuitOpen", err)
+	}
+	if n := s.Calls(metaapitest.EndpointVerifyEntitlement); n != 2 {
+		t.Errorf("%v Graph calls, want 2", n)
+	}
+
+	// breakers are per endpoint
+	if _, err := repo.AllItemsOwned(ctx, metaapiwrapper.RetrieveItemsOwnedQuery{OrgScopedID: "u1"}); err != nil {
+		t.Errorf("viewer_purchases: %v", err)
+	}
+
+	// a failed probe opens the circuit again
+	time.Sleep(2 * coolDown)
+	if err := verify(); !metaapiwrapper.IsTransient(err) {
+		t.Fatalf("first probe: got %v, want the transient error", err)
+	}
+	if err := verify(); !errors.Is(err, metaapiwrapper.ErrCircuitOpen) {
+		t.Fatalf("after the failed probe: got %v, want ErrCircuitOpen", err)
+	}
+
+	s.ClearErrors()
+	time.Sleep(2 * coolDown)
+	for i := 0; i < 2; i++ {
+		if err := verify(); err != nil {
+			t.Fatalf("after recovery %v: %v", i, err)
+		}
+	}
+
+	endpoint := metaapiwrapper.EndpointVerifyEntitlement
+	want := []string{
+		endpoint + " closed>open",
+		endpoint + " open>half-open",
+		endpoint + " half-open>open",
+		endpoint + " open>half-open",
+		endpoint + " half-open>closed",
+	}
+	if got := log.get(); !slices.Equal(got, want) {
+		t.Errorf("state changes %v, want %v", got, want)
+	}
+}
+
+func TestCircuitBreakerIgnoresRejections(t *testing.T) {
+	s := metaapitest.NewServer("app", "secret")
+	defer s.Close()
+
+	repo := metaapiwrapper.NewMetaApiRepository(append(s.Options(), metaapiwrapper.WithCircuitBreaker(metaapiwrapper.BreakerConfig{FailureThreshold: 1}))...)
+	ctx := context.Background()
+	q := metaapiwrapper.VerifyItemOwnershipQuery{UsrID: "u1", SKU: "sku"}
+
+	for i := 0; i < 3; i++ {
+		if _, err := repo.RequestOculusVerifyItemOwnershipCtx(ctx, q); !errors.Is(err, metaapiwrapper.ErrEntitlementMissing) {
+			t.Fatalf("missing %v: got %v, want ErrEntitlementMissing", i, err)
+		}
+	}
+
+	s.InjectError(metaapitest.EndpointVerifyEntitlement, metaapiwrapper.MetaAPIError{StatusCode: http.StatusBadRequest, Code: 190, Message: "bad token"}, 0)
+	for i := 0; i < 3; i++ {
+		if _, err := repo.RequestOculusVerifyItemOwnershipCtx(ctx, q); !metaapiwrapper.IsInvalidToken(err) {
+			t.Fatalf("rejected %v: got %v, want invalid token", i, err)
+		}
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := repo.RequestOculusVerifyItemOwnershipCtx(cancelled, q); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+	if _, err := repo.RequestOculusVerifyItemOwnershipCtx(ctx, q); !metaapiwrapper.IsInvalidToken(err) {
+		t.Fatalf("after the cancellation: got %v, want invalid token", err)
+	}
+
+	if n := s.Calls(metaapitest.EndpointVerifyEntitlement); n != 7 {
+		t.Errorf("%v Graph calls, want 7", n)
+	}
+}
+
+func TestOwnershipFallback(t *testing.T) {
+	s := metaapitest.NewServer("app", "secret")
+	defer s.Close()
+	s.AddEntitlement("u1", metaapitest.Entitlement{SKU: "owned"})
+
+	repo := metaapiwrapper.NewMetaApiRepository(append(s.Options(),
+		metaapiwrapper.WithCircuitBreaker(metaapiwrapper.BreakerConfig{FailureThreshold: 1, CoolDown: time.Hour}),
+		metaapiwrapper.WithOwnershipFallback(metaapiwrapper.NewLastKnownOwnership(nil, time.Hour)),
+	)...)
+	ctx := context.Background()
+	verify := func(sku string) error {
+		_, err := repo.RequestOculusVerifyItemOwnershipCtx(ctx, metaapiwrapper.VerifyItemOwnershipQuery{UsrID: "u1", SKU: sku})
+		return err
+	}
+
+	if err := verify("owned"); err != nil {
+		t.Fatal(err)
+	}
+	if err := verify("missing"); !errors.Is(err, metaapiwrapper.ErrEntitlementMissing) {
+		t.Fatalf("got %v, want ErrEntitlementMissing", err)
+	}
+
+	s.InjectError(metaapitest.EndpointVerifyEntitlement, graphDown, 0)
+	if err := verify("owned"); !metaapiwrapper.IsTransient(err) {
+		t.Fatalf("got %v, want the transient error", err)
+	}
+
+	// served from the last known answers without calling Graph
+	calls := s.Calls(metaapitest.EndpointVerifyEntitlement)
+	if err := verify("owned"); err != nil {
+		t.Errorf("owned while open: %v", err)
+	}
+	if err := verify("missing"); !errors.Is(err, metaapiwrapper.ErrEntitlementMissing) {
+		t.Errorf("missing while open: got %v, want ErrEntitlementMissing", err)
+	}
+	if err := verify("unknown"); !errors.Is(err, metaapiwrapper.ErrCircuitOpen) {
+		t.Errorf("never seen while open: got %v, want ErrCircuitOpen", err)
+	}
+	if n := s.Calls(metaapitest.EndpointVerifyEntitlement); n != calls {
+		t.Errorf("%v Graph calls while open, want %v", n, calls)
+	}
+}
@@ -0,0 +1,322 @@
+package metaapiwrapper
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	defaultPositiveTTL  = 5 * time.Minute
+	defaultNegativeTTL  = 30 * time.Second
+	defaultLRUCacheSize = 10000
+
+	verifyCacheKeyPrefix    = "verify|"
+	purchasesCacheKeyPrefix = "purchases|"
+)
+
+// EntitlementCache stores serialized lookups for CachedMetaApiRepository; implement it to share a cache between instances
+type EntitlementCache interface {
+	Get(key string) (value []byte, ok bool)
+	Set(key string, value []byte, ttl time.Duration)
+	Delete(key string)
+	DeletePrefix(prefix string)
+}
+
+// CacheConfig tunes CachedMetaApiRepository; the zero value is usable
+type CacheConfig struct {
+	// PositiveTTL keeps owned entitlements and non-empty purchase pages, 5 minutes when 0
+	PositiveTTL time.Duration
+	// NegativeTTL keeps missing entitlements and empty purchase pages, 30 seconds when 0
+	NegativeTTL time.Duration
+	// Backend defaults to an in-memory LRU of 10000 entries
+	Backend EntitlementCache
+}
+
+// CachedMetaApiRepository caches verify_entitlement and viewer_purchases in front of another MetaApiRepository.
+// Concurrent identical lookups are collapsed into one Graph call; everything else is passed through
+type CachedMetaApiRepository struct {
+	MetaApiRepository
+
+	positiveTTL time.Duration
+	negativeTTL time.Duration
+	backend     EntitlementCache
+
+	group singleflight.Group
+
+	// generation is bumped by every invalidation; answers of lookups that started before it are not cached
+	mu         sync.Mutex
+	generation uint64
+}
+
+func NewCachedMetaApiRepository(inner MetaApiRepository, cfg CacheConfig) *CachedMetaApiRepository {
+	c := &CachedMetaApiRepository{
+		MetaApiRepository: inner,
+		positiveTTL:       cfg.PositiveTTL,
+		negativeTTL:       cfg.NegativeTTL,
+		backend:           cfg.Backend,
+	}
+
+	if c.positiveTTL <= 0 {
+		c.positiveTTL = defaultPositiveTTL
+	}
+	if c.negativeTTL <= 0 {
+		c.negativeTTL = defaultNegativeTTL
+	}
+	if c.backend == nil {
+		c.backend = NewLRUEntitlementCache(defaultLRUCacheSize)
+	}
+	return c
+}
+
+func verifyCacheKey(userID, sku string) string {
+	return fmt.Sprintf("%v%v|%v", verifyCacheKeyPrefix, userID, sku)
+}
+
+func purchasesCacheKey(q RetrieveItemsOwnedQuery) string {
+	return fmt.Sprintf("%v%v|%v|%v", purchasesCacheKeyPrefix, q.OrgScopedID, strings.Join(q.Fields, ","), q.After)
+}
+
+// Invalidate drops the cached ownership of sku and every cached purchase page of userID, e.g. after a purchase
+func (c *CachedMetaApiRepository) Invalidate(userID, sku string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.group.Forget(verifyCacheKey(userID, sku))
+	c.backend.Delete(verifyCacheKey(userID, sku))
+	c.backend.DeletePrefix(purchasesCacheKeyPrefix + userID + "|")
+}
+
+// InvalidateUser drops everything cached for userID.
+// verify_entitlement is keyed by the app scoped ID and viewer_purchases by the ID it was queried with, so pass both when they differ
+func (c *CachedMetaApiRepository) InvalidateUser(userID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.backend.DeletePrefix(verifyCacheKeyPrefix + userID + "|")
+	c.backend.DeletePrefix(purchasesCacheKeyPrefix + userID + "|")
+}
+
+func (c *CachedMetaApiRepository) currentGeneration() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.generation
+}
+
+// set caches value unless an invalidation ran since generation was read, which may have dropped
+// the very entry a lookup that was still in flight is about to store
+func (c *CachedMetaApiRepository) set(generation uint64, key string, value []byte, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.generation == generation {
+		c.backend.Set(key, value, ttl)
+	}
+}
+
+// shared runs fn once for concurrent callers of key, see singleflightCtx
+func (c *CachedMetaApiRepository) shared(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	return singleflightCtx(ctx, &c.group, key, fn)
+}
+
+// singleflightCtx runs fn once in group for concurrent callers of key. A caller whose own ctx is still alive
+// does not inherit the cancellation of the caller that happened to run fn
+func singleflightCtx(ctx context.Context, group *singleflight.Group, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	v, err, _ := group.Do(key, func() (interface{}, error) {
+		return fn(ctx)
+	})
+
+	if err != nil && ctx.Err() == nil && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) {
+		return fn(ctx)
+	}
+	return v, err
+}
+
+func (c *CachedMetaApiRepository) RequestOculusVerifyItemOwnership(q VerifyItemOwnershipQuery) (OculusResp OCULUSResponseBase, err error) {
+	return c.RequestOculusVerifyItemOwnershipCtx(context.Background(), q)
+}
+
+// RequestOculusVerifyItemOwnershipCtx answers from cache when possible; ErrEntitlementMissing is cached for the negative TTL, other errors are not cached
+func (c *CachedMetaApiRepository) RequestOculusVerifyItemOwnershipCtx(ctx context.Context, q VerifyItemOwnershipQuery) (OculusResp OCULUSResponseBase, err error) {
+	key := verifyCacheKey(q.UsrID, q.SKU)
+	if cached, ok := c.backend.Get(key); ok {
+		if string(cached) == "1" {
+			OculusResp.Success = true
+			return
+		}
+		err = ErrEntitlementMissing
+		return
+	}
+
+	var v interface{}
+	v, err = c.shared(ctx, key, func(ctx context.Context) (interface{}, error) {
+		generation := c.currentGeneration()
+		resp, err := c.MetaApiRepository.RequestOculusVerifyItemOwnershipCtx(ctx, q)
+		switch {
+		case err == nil:
+			c.set(generation, key, []byte("1"), c.positiveTTL)
+		case errors.Is(err, ErrEntitlementMissing):
+			c.set(generation, key, []byte("0"), c.negativeTTL)
+		}
+		return resp, err
+	})
+
+	OculusResp, _ = v.(OCULUSResponseBase)
+	return
+}
+
+func (c *CachedMetaApiRepository) RequestOculusRetrieveItemsOwned(q RetrieveItemsOwnedQuery) (oculusResp RetrieveItemsOwnedResponse, err error) {
+	return c.RequestOculusRetrieveItemsOwnedCtx(context.Background(), q)
+}
+
+// RequestOculusRetrieveItemsOwnedCtx caches each page; empty pages are kept for the negative TTL, errors are not cached
+func (c *CachedMetaApiRepository) RequestOculusRetrieveItemsOwnedCtx(ctx context.Context, q RetrieveItemsOwnedQuery) (oculusResp RetrieveItemsOwnedResponse, err error) {
+	key := purchasesCacheKey(q)
+	if cached, ok := c.backend.Get(key); ok {
+		if json.Unmarshal(cached, &oculusResp) == nil {
+			return
+		}
+		oculusResp = RetrieveItemsOwnedResponse{}
+	}
+
+	var v interface{}
+	v, err = c.shared(ctx, key, func(ctx context.Context) (interface{}, error) {
+		generation := c.currentGeneration()
+		resp, err := c.MetaApiRepository.RequestOculusRetrieveItemsOwnedCtx(ctx, q)
+		if err != nil {
+			return resp, err
+		}
+
+		ttl := c.positiveTTL
+		if len(resp.Data) <= 0 {
+			ttl = c.negativeTTL
+		}
+		if encoded, jsonErr := json.Marshal(resp); jsonErr == nil {
+			c.set(generation, key, encoded, ttl)
+		}
+		return resp, nil
+	})
+
+	oculusResp, _ = v.(RetrieveItemsOwnedResponse)
+	return
+}
+
+// IterItemsOwned walks pages through the cache
+func (c *CachedMetaApiRepository) IterItemsOwned(ctx context.Context, q RetrieveItemsOwnedQuery) iter.Seq2[OculusData, error] {
+	return iterItemsOwned(ctx, c.RequestOculusRetrieveItemsOwnedCtx, q)
+}
+
+func (c *CachedMetaApiRepository) AllItemsOwned(ctx context.Context, q RetrieveItemsOwnedQuery) (items []OculusData, err error) {
+	return allItemsOwned(c.IterItemsOwned(ctx, q))
+}
+
+func (c *CachedMetaApiRepository) RequestOculusConsumeIAPItem(q OculusConsumeIAPItemQuery) (OculusResp OCULUSResponseBase, err error) {
+	return c.RequestOculusConsumeIAPItemCtx(context.Background(), q)
+}
+
+// RequestOculusConsumeIAPItemCtx consumes through the wrapped repository and invalidates the user's cached ownership of the SKU
+func (c *CachedMetaApiRepository) RequestOculusConsumeIAPItemCtx(ctx context.Context, q OculusConsumeIAPItemQuery) (OculusResp OCULUSResponseBase, err error) {
+	defer c.Invalidate(q.UsrID, q.SKU)
+
+	return c.MetaApiRepository.RequestOculusConsumeIAPItemCtx(ctx, q)
+}
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// LRUEntitlementCache is an in-memory EntitlementCache bounded by entry count, with per entry TTL
+type LRUEntitlementCache struct {
+	capacity int
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+func NewLRUEntitlementCache(capacity int) *LRUEntitlementCache {
+	if capacity <= 0 {
+		capacity = defaultLRUCacheSize
+	}
+
+	return &LRUEntitlementCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  map[string]*list.Element{},
+	}
+}
+
+func (l *LRUEntitlementCache) Get(key string) (value []byte, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	elem, ok := l.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*lruEntry)
+	if time.Now().After(entry.expires) {
+		l.remove(elem)
+		return nil, false
+	}
+
+	l.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+func (l *LRUEntitlementCache) Set(key string, value []byte, ttl time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	expires := time.Now().Add(ttl)
+	if elem, ok := l.entries[key]; ok {
+		entry := elem.Value.(*lruEntry)
+		entry.value, entry.expires = value, expires
+		l.order.MoveToFront(elem)
+		return
+	}
+
+	l.entries[key] = l.order.PushFront(&lruEntry{key: key, value: value, expires: expires})
+	for l.order.Len() > l.capacity {
+		l.remove(l.order.Back())
+	}
+}
+
+func (l *LRUEntitlementCache) Delete(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elem, ok := l.entries[key]; ok {
+		l.remove(elem)
+	}
+}
+
+func (l *LRUEntitlementCache) DeletePrefix(prefix string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for key, elem := range l.entries {
+		if strings.HasPrefix(key, prefix) {
+			l.remove(elem)
+		}
+	}
+}
+
+func (l *LRUEntitlementCache) remove(elem *list.Element) {
+	l.order.Remove(elem)
+	delete(l.entries, elem.Value.(*lruEntry).key)
+}
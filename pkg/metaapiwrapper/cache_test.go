@@ -0,0 +1,214 @@
+package metaapiwrapper_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hyperbting/api-library/pkg/metaapiwrapper"
+	"github.com/hyperbting/api-library/pkg/metaapiwrapper/metaapitest"
+)
+
+func TestCachedRepositoryVerify(t *testing.T) {
+	s := metaapitest.NewServer("app", "secret")
+	defer s.Close()
+	s.AddEntitlement("u1", metaapitest.Entitlement{SKU: "owned"})
+
+	cached := metaapiwrapper.NewCachedMetaApiRepository(metaapiwrapper.NewMetaApiRepository(s.Options()...), metaapiwrapper.CacheConfig{})
+	ctx := context.Background()
+	verify := func(sku string) error {
+		_, err := cached.RequestOculusVerifyItemOwnershipCtx(ctx, metaapiwrapper.VerifyItemOwnershipQuery{UsrID: "u1", SKU: sku})
+		return err
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := verify("owned"); err != nil {
+			t.Fatalf("owned %v: %v", i, err)
+		}
+		if err := verify("missing"); !errors.Is(err, metaapiwrapper.ErrEntitlementMissing) {
+			t.Fatalf("missing %v: got %v, want ErrEntitlementMissing", i, err)
+		}
+	}
+	if n := s.Calls(metaapitest.EndpointVerifyEntitlement); n != 2 {
+		t.Errorf("%v Graph calls, want 2", n)
+	}
+
+	// a purchase is only seen once the negative entry is dropped
+	s.AddEntitlement("u1", metaapitest.Entitlement{SKU: "missing"})
+	if err := verify("missing"); !errors.Is(err, metaapiwrapper.ErrEntitlementMissing) {
+		t.Errorf("before Invalidate: got %v, want the cached ErrEntitlementMissing", err)
+	}
+	cached.Invalidate("u1", "missing")
+	if err := verify("missing"); err != nil {
+		t.Errorf("after Invalidate: %v", err)
+	}
+}
+
+func TestCachedRepositoryVerifyErrorNotCached(t *testing.T) {
+	s := metaapitest.NewServer("app", "secret")
+	defer s.Close()
+	s.AddEntitlement("u1", metaapitest.Entitlement{SKU: "owned"})
+	s.InjectError(metaapitest.EndpointVerifyEntitlement, metaapiwrapper.MetaAPIError{StatusCode: http.StatusInternalServerError, Code: 2, Message: "down"}, 1)
+
+	cached := metaapiwrapper.NewCachedMetaApiRepository(metaapiwrapper.NewMetaApiRepository(s.Options()...), metaapiwrapper.CacheConfig{})
+	q := metaapiwrapper.VerifyItemOwnershipQuery{UsrID: "u1", SKU: "owned"}
+
+	if _, err := cached.RequestOculusVerifyItemOwnershipCtx(context.Background(), q); !metaapiwrapper.IsTransient(err) {
+		t.Fatalf("got %v, want the transient error", err)
+	}
+	if _, err := cached.RequestOculusVerifyItemOwnershipCtx(context.Background(), q); err != nil {
+		t.Errorf("after the error: %v", err)
+	}
+}
+
+func TestCachedRepositoryConsumeInvalidates(t *testing.T) {
+	s := metaapitest.NewServer("app", "secret")
+	defer s.Close()
+	s.AddEntitlement("u1", metaapitest.Entitlement{SKU: "gem", Consumable: true})
+
+	cached := metaapiwrapper.NewCachedMetaApiRepository(metaapiwrapper.NewMetaApiRepository(s.Options()...), metaapiwrapper.CacheConfig{})
+	ctx := context.Background()
+	q := metaapiwrapper.VerifyItemOwnershipQuery{UsrID: "u1", SKU: "gem"}
+
+	if _, err := cached.RequestOculusVerifyItemOwnershipCtx(ctx, q); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cached.RequestOculusConsumeIAPItemCtx(ctx, metaapiwrapper.OculusConsumeIAPItemQuery{VerifyItemOwnershipQuery: q}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cached.RequestOculusVerifyItemOwnershipCtx(ctx, q); !errors.Is(err, metaapiwrapper.ErrEntitlementMissing) {
+		t.Errorf("got %v, want ErrEntitlementMissing after consuming", err)
+	}
+}
+
+func TestCachedRepositoryPurchases(t *testing.T) {
+	s := metaapitest.NewServer("app", "secret")
+	defer s.Close()
+	s.PageSize = 1
+	s.AddEntitlement("u1", metaapitest.Entitlement{ID: "e1", SKU: "a"})
+	s.AddEntitlement("u1", metaapitest.Entitlement{ID: "e2", SKU: "b"})
+
+	cached := metaapiwrapper.NewCachedMetaApiRepository(metaapiwrapper.NewMetaApiRepository(s.Options()...), metaapiwrapper.CacheConfig{})
+	ctx := context.Background()
+	q := metaapiwrapper.RetrieveItemsOwnedQuery{OrgScopedID: "u1"}
+
+	items, err := cached.AllItemsOwned(ctx, q)
+	if err != nil || len(items) != 2 {
+		t.Fatalf("got %+v, %v", items, err)
+	}
+	pages := s.Calls(metaapitest.EndpointViewerPurchases)
+
+	if items, err = cached.AllItemsOwned(ctx, q); err != nil || len(items) != 2 {
+		t.Fatalf("cached: got %+v, %v", items, err)
+	}
+	if n := s.Calls(metaapitest.EndpointViewerPurchases); n != pages {
+		t.Errorf("%v Graph calls after a cached walk, want %v", n, pages)
+	}
+
+	cached.InvalidateUser("u1")
+	if _, err = cached.AllItemsOwned(ctx, q); err != nil {
+		t.Fatal(err)
+	}
+	if n := s.Calls(metaapitest.EndpointViewerPurchases); n != 2*pages {
+		t.Errorf("%v Graph calls after InvalidateUser, want %v", n, 2*pages)
+	}
+}
+
+func TestCachedRepositoryExpiry(t *testing.T) {
+	s := metaapitest.NewServer("app", "secret")
+	defer s.Close()
+	s.AddEntitlement("u1", metaapitest.Entitlement{SKU: "owned"})
+
+	cached := metaapiwrapper.NewCachedMetaApiRepository(metaapiwrapper.NewMetaApiRepository(s.Options()...), metaapiwrapper.CacheConfig{PositiveTTL: 10 * time.Millisecond})
+	q := metaapiwrapper.VerifyItemOwnershipQuery{UsrID: "u1", SKU: "owned"}
+
+	for i := 0; i < 2; i++ {
+		if _, err := cached.RequestOculusVerifyItemOwnershipCtx(context.Background(), q); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if n := s.Calls(metaapitest.EndpointVerifyEntitlement); n != 2 {
+		t.Errorf("%v Graph calls, want 2", n)
+	}
+}
+
+// blockingVerifyRepository answers verify_entitlement once release is closed
+type blockingVerifyRepository struct {
+	metaapiwrapper.MetaApiRepository
+
+	calls   atomic.Int32
+	started chan struct{}
+	release chan struct{}
+}
+
+func (r *blockingVerifyRepository) RequestOculusVerifyItemOwnershipCtx(ctx context.Context, q metaapiwrapper.VerifyItemOwnershipQuery) (metaapiwrapper.OCULUSResponseBase, error) {
+	if r.calls.Add(1) == 1 {
+		close(r.started)
+	}
+	<-r.release
+	return metaapiwrapper.OCULUSResponseBase{Success: true}, nil
+}
+
+func TestCachedRepositoryCollapsesConcurrentLookups(t *testing.T) {
+	inner := &blockingVerifyRepository{started: make(chan struct{}), release: make(chan struct{})}
+	cached := metaapiwrapper.NewCachedMetaApiRepository(inner, metaapiwrapper.CacheConfig{})
+	q := metaapiwrapper.VerifyItemOwnershipQuery{UsrID: "u1", SKU: "owned"}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := cached.RequestOculusVerifyItemOwnershipCtx(context.Background(), q)
+			errs <- err
+		}()
+	}
+
+	<-inner.started
+	// give the other callers time to join
+	time.Sleep(20 * time.Millisecond)
+	close(inner.release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if n := inner.calls.Load(); n != 1 {
+		t.Errorf("%v lookups reached the wrapped repository, want 1", n)
+	}
+}
+
+func TestCachedRepositoryInvalidateDuringLookup(t *testing.T) {
+	inner := &blockingVerifyRepository{started: make(chan struct{}), release: make(chan struct{})}
+	cached := metaapiwrapper.NewCachedMetaApiRepository(inner, metaapiwrapper.CacheConfig{})
+	q := metaapiwrapper.VerifyItemOwnershipQuery{UsrID: "u1", SKU: "gem"}
+
+	// Graph answers "owned" before the consume, the answer is stored after its invalidation
+	done := make(chan error, 1)
+	go func() {
+		_, err := cached.RequestOculusVerifyItemOwnershipCtx(context.Background(), q)
+		done <- err
+	}()
+	<-inner.started
+	cached.Invalidate("u1", "gem")
+	close(inner.release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cached.RequestOculusVerifyItemOwnershipCtx(context.Background(), q); err != nil {
+		t.Fatal(err)
+	}
+	if n := inner.calls.Load(); n != 2 {
+		t.Errorf("%v lookups reached the wrapped repository, want 2: the stale answer was cached", n)
+	}
+}
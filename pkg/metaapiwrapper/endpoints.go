@@ -0,0 +1,100 @@
+package metaapiwrapper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalidAPIVersion is returned by every call of a repository configured with a malformed WithAPIVersion
+var ErrInvalidAPIVersion = errors.New("metaapiwrapper: invalid Graph API version")
+
+var apiVersionPattern = regexp.MustCompile(`^v[0-9]+\.[0-9]+$`)
+
+// path placeholders substituted, escaped, by endpointPath
+const (
+	appIDSegment  = "{app_id}"
+	userIDSegment = "{user_id}"
+)
+
+type graphEndpoint struct {
+	method   string
+	segments []string
+}
+
+// graphEndpoints defines every Graph call of the repository, keyed by its instrumentation name
+var graphEndpoints = map[string]graphEndpoint{
+	EndpointUserNonceValidate:  {http.MethodPost, []string{urlSegment(UserNonceValidateUrl)}},
+	EndpointVerifyEntitlement:  {http.MethodGet, []string{appIDSegment, urlSegment(VerifyItemOwnershipUrl)}},
+	EndpointConsumeEntitlement: {http.MethodGet, []string{appIDSegment, urlSegment(ConsumeIAPItemUrl)}},
+	EndpointViewerPurchases:    {http.MethodGet, []string{appIDSegment, urlSegment(RetrieveItemsOwnedUrl)}},
+	EndpointOrgScopedID:        {http.MethodGet, []string{userIDSegment}},
+
+	EndpointAchievementDefinitions: {http.MethodGet, []string{appIDSegment, urlSegment(AchievementDefinitionsUrl)}},
+	EndpointUserAchievements:       {http.MethodGet, []string{userIDSegment, urlSegment(UserAchievementsUrl)}},
+	EndpointWriteAchievement:       {http.MethodPost, []string{userIDSegment, urlSegment(UserAchievementsUrl)}},
+
+	EndpointLeaderboards:           {http.MethodGet, []string{appIDSegment, urlSegment(LeaderboardsUrl)}},
+	EndpointLeaderboardSubmitEntry: {http.MethodPost, []string{urlSegment(LeaderboardSubmitEntryUrl)}},
+	EndpointLeaderboardEntries:     {http.MethodGet, []string{urlSegment(LeaderboardEntriesUrl)}},
+	EndpointLeaderboardRemoveEntry: {http.MethodDelete, []string{urlSegment(LeaderboardRemoveEntryUrl)}},
+
+	EndpointSubscriptions: {http.MethodGet, []string{appIDSegment, urlSegment(SubscriptionsUrl)}},
+}
+
+func urlSegment(path string) string {
+	return strings.TrimPrefix(path, "/")
+}
+
+// WithAPIVersion pins every call to a Graph API version such as "v19.0"; unversioned when empty
+func WithAPIVersion(version string) Option {
+	return func(m *metaApiRepositoryImpl) {
+		m.apiVersion = version
+	}
+}
+
+// endpointPath builds the path of endpoint for userID, with the API version in front and the app and user IDs escaped
+func (m *metaApiRepositoryImpl) endpointPath(endpoint, userID string) (path string, err error) {
+	spec, ok := graphEndpoints[endpoint]
+	if !ok {
+		return "", fmt.Errorf("metaapiwrapper: unknown endpoint %v", endpoint)
+	}
+
+	var b strings.Builder
+	if len(m.apiVersion) > 0 {
+		if !apiVersionPattern.MatchString(m.apiVersion) {
+			return "", fmt.Errorf("%w: %q", ErrInvalidAPIVersion, m.apiVersion)
+		}
+		b.WriteString("/" + m.apiVersion)
+	}
+
+	for _, segment := range spec.segments {
+		switch segment {
+		case appIDSegment:
+			if segment = m.AccessToken.AppID; len(segment) <= 0 {
+				return "", fmt.Errorf("metaapiwrapper: %v needs an app ID", endpoint)
+			}
+		case userIDSegment:
+			if segment = userID; len(segment) <= 0 {
+				return "", fmt.Errorf("metaapiwrapper: %v needs a user ID", endpoint)
+			}
+		}
+
+		b.WriteString("/" + url.PathEscape(segment))
+	}
+	return b.String(), nil
+}
+
+// newEndpointRequest builds the request of endpoint for userID, which is ignored by endpoints without a user in their path
+func (m *metaApiRepositoryImpl) newEndpointRequest(ctx context.Context, endpoint, userID string, params url.Values) (req *http.Request, err error) {
+	var path string
+	if path, err = m.endpointPath(endpoint, userID); err != nil {
+		return
+	}
+
+	return m.newRequest(ctx, graphEndpoints[endpoint].method, path, params)
+}
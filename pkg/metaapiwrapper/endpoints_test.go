@@ -0,0 +1,98 @@
+package metaapiwrapper
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestEndpointPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		appID    string
+		noAppID  bool
+		userID   string
+		version  string
+		want     string
+		method   string
+		wantErr  error
+		errMatch string
+	}{
+		{name: "nonce", endpoint: EndpointUserNonceValidate, want: "/user_nonce_validate", method: http.MethodPost},
+		{name: "verify", endpoint: EndpointVerifyEntitlement, want: "/app/verify_entitlement", method: http.MethodGet},
+		{name: "consume", endpoint: EndpointConsumeEntitlement, want: "/app/consume_entitlement", method: http.MethodGet},
+		{name: "purchases", endpoint: EndpointViewerPurchases, want: "/app/viewer_purchases", method: http.MethodGet},
+		{name: "org scoped id", endpoint: EndpointOrgScopedID, userID: "u1", want: "/u1", method: http.MethodGet},
+		{name: "achievement definitions", endpoint: EndpointAchievementDefinitions, want: "/app/achievement_definitions", method: http.MethodGet},
+		{name: "user achievements", endpoint: EndpointUserAchievements, userID: "u1", want: "/u1/achievements", method: http.MethodGet},
+		{name: "write achievement", endpoint: EndpointWriteAchievement, userID: "u1", want: "/u1/achievements", method: http.MethodPost},
+		{name: "leaderboards", endpoint: EndpointLeaderboards, want: "/app/leaderboards", method: http.MethodGet},
+		{name: "submit entry", endpoint: EndpointLeaderboardSubmitEntry, want: "/leaderboard_submit_entry", method: http.MethodPost},
+		{name: "entries", endpoint: EndpointLeaderboardEntries, want: "/leaderboard_entries", method: http.MethodGet},
+		{name: "remove entry", endpoint: EndpointLeaderboardRemoveEntry, want: "/leaderboard_remove_entry", method: http.MethodDelete},
+		{name: "subscriptions", endpoint: EndpointSubscriptions, want: "/app/subscriptions", method: http.MethodGet},
+
+		{name: "versioned", endpoint: EndpointVerifyEntitlement, version: "v19.0", want: "/v19.0/app/verify_entitlement", method: http.MethodGet},
+		{name: "versioned user", endpoint: EndpointOrgScopedID, userID: "u1", version: "v21.0", want: "/v21.0/u1", method: http.MethodGet},
+		{name: "version without v", endpoint: EndpointVerifyEntitlement, version: "19.0", wantErr: ErrInvalidAPIVersion},
+		{name: "version without minor", endpoint: EndpointVerifyEntitlement, version: "v19", wantErr: ErrInvalidAPIVersion},
+		{name: "version with a path", endpoint: EndpointVerifyEntitlement, version: "v19.0/../x", wantErr: ErrInvalidAPIVersion},
+
+		{name: "app ID with a slash", endpoint: EndpointViewerPurchases, appID: "a/b", want: "/a%2Fb/viewer_purchases", method: http.MethodGet},
+		{name: "app ID with a space", endpoint: EndpointViewerPurchases, appID: "a b", want: "/a%20b/viewer_purchases", method: http.MethodGet},
+		{name: "user ID with a slash", endpoint: EndpointUserAchievements, userID: "a/b", want: "/a%2Fb/achievements", method: http.MethodGet},
+		{name: "user ID with a space", endpoint: EndpointOrgScopedID, userID: "a b", want: "/a%20b", method: http.MethodGet},
+
+		{name: "empty app ID", endpoint: EndpointVerifyEntitlement, noAppID: true, errMatch: "needs an app ID"},
+		{name: "empty user ID", endpoint: EndpointOrgScopedID, errMatch: "needs a user ID"},
+		{name: "empty user ID ignored", endpoint: EndpointLeaderboards, want: "/app/leaderboards", method: http.MethodGet},
+		{name: "unknown endpoint", endpoint: "nope", errMatch: "unknown endpoint nope"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			appID := tt.appID
+			if len(appID) <= 0 && !tt.noAppID {
+				appID = "app"
+			}
+			m := NewMetaApiRepository(
+				WithPlatformConfig(OCULUSPlatformConfig{AppID: appID, AppSecret: "secret"}),
+				WithAPIVersion(tt.version),
+			).(*metaApiRepositoryImpl)
+
+			path, err := m.endpointPath(tt.endpoint, tt.userID)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got %q, %v; want %v", path, err, tt.wantErr)
+				}
+				return
+			case len(tt.errMatch) > 0:
+				if err == nil || !strings.Contains(err.Error(), tt.errMatch) {
+					t.Fatalf("got %q, %v; want an error containing %q", path, err, tt.errMatch)
+				}
+				return
+			case err != nil:
+				t.Fatal(err)
+			}
+			if path != tt.want {
+				t.Errorf("path %q, want %q", path, tt.want)
+			}
+
+			// the escaping must survive into the request, not be decoded by URL parsing
+			req, err := m.newEndpointRequest(context.Background(), tt.endpoint, tt.userID, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if req.Method != tt.method {
+				t.Errorf("method %v, want %v", req.Method, tt.method)
+			}
+			if got := req.URL.EscapedPath(); got != tt.want {
+				t.Errorf("request path %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
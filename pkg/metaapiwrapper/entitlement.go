@@ -0,0 +1,134 @@
+package metaapiwrapper
+
+import (
+	"time"
+)
+
+type EntitlementKind int
+
+const (
+	EntitlementDurable EntitlementKind = iota
+	EntitlementConsumable
+	EntitlementSubscription
+)
+
+func (k EntitlementKind) String() string {
+	switch k {
+	case EntitlementConsumable:
+		return "consumable"
+	case EntitlementSubscription:
+		return "subscription"
+	default:
+		return "durable"
+	}
+}
+
+// KindResolver tells the kind of a SKU. viewer_purchases cannot tell consumables from durables,
+// so without one anything expiring is a subscription and everything else durable
+type KindResolver func(sku string) EntitlementKind
+
+// Entitlement is OculusData with real times
+type Entitlement struct {
+	ID     string
+	SKU    string
+	ItemID string
+	Kind   EntitlementKind
+
+	GrantedAt time.Time
+	// ExpiresAt is zero for entitlements that never expire
+	ExpiresAt time.Time
+}
+
+// IsActive reports whether the entitlement is granted and not expired at now
+func (e Entitlement) IsActive(now time.Time) bool {
+	if !e.GrantedAt.IsZero() && now.Before(e.GrantedAt) {
+		return false
+	}
+	return e.ExpiresAt.IsZero() || now.Before(e.ExpiresAt)
+}
+
+// ExpiresIn is the time left at now, negative once expired; expires is false when it never does
+func (e Entitlement) ExpiresIn(now time.Time) (left time.Duration, expires bool) {
+	if e.ExpiresAt.IsZero() {
+		return 0, false
+	}
+	return e.ExpiresAt.Sub(now), true
+}
+
+// outlasts reports whether e stays active longer than other
+func (e Entitlement) outlasts(other Entitlement) bool {
+	switch {
+	case e.ExpiresAt.IsZero() != other.ExpiresAt.IsZero():
+		return e.ExpiresAt.IsZero()
+	case !e.ExpiresAt.Equal(other.ExpiresAt):
+		return e.ExpiresAt.After(other.ExpiresAt)
+	default:
+		return e.GrantedAt.After(other.GrantedAt)
+	}
+}
+
+func unixTime(seconds int64) time.Time {
+	if seconds <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0)
+}
+
+// Entitlement converts the raw Graph record; resolve may be nil
+func (d OculusData) Entitlement(resolve KindResolver) Entitlement {
+	e := Entitlement{
+		ID:        d.ID,
+		SKU:       d.Item.SKU,
+		ItemID:    d.Item.ID,
+		GrantedAt: unixTime(d.GrantTime),
+		ExpiresAt: unixTime(d.ExpirationTime),
+	}
+
+	switch {
+	case resolve != nil:
+		e.Kind = resolve(e.SKU)
+	case !e.ExpiresAt.IsZero():
+		e.Kind = EntitlementSubscription
+	default:
+		e.Kind = EntitlementDurable
+	}
+	return e
+}
+
+// EntitlementSet holds one entitlement per SKU
+type EntitlementSet map[string]Entitlement
+
+// NewEntitlementSet de-duplicates items by SKU, keeping the record that stays active longest, e.g. for AllItemsOwned
+func NewEntitlementSet(items []OculusData, resolve KindResolver) EntitlementSet {
+	set := make(EntitlementSet, len(items))
+	for _, data := range items {
+		e := data.Entitlement(resolve)
+		if known, ok := set[e.SKU]; ok && !e.outlasts(known) {
+			continue
+		}
+		set[e.SKU] = e
+	}
+	return set
+}
+
+// Entitlements is NewEntitlementSet of this page
+func (r RetrieveItemsOwnedResponse) Entitlements(resolve KindResolver) EntitlementSet {
+	return NewEntitlementSet(r.Data, resolve)
+}
+
+// Has reports whether sku is owned and active at now
+func (s EntitlementSet) Has(sku string, now time.Time) bool {
+	e, ok := s[sku]
+	return ok && e.IsActive(now)
+}
+
+// Active keeps the entitlements active at now
+func (s EntitlementSet) Active(now time.Time) EntitlementSet {
+	active := make(EntitlementSet, len(s))
+	for sku, e := range s {
+		if e.IsActive(now) {
+			active[sku] = e
+		}
+	}
+	return active
+}
@@ -0,0 +1,168 @@
+package metaapiwrapper_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperbting/api-library/pkg/metaapiwrapper"
+)
+
+var entitlementNow = time.Unix(1_700_000_000, 0)
+
+// purchase is an OculusData of sku granted and expiring relative to entitlementNow; 0 leaves the time unset
+func purchase(id, sku string, granted, expires time.Duration) metaapiwrapper.OculusData {
+	data := metaapiwrapper.OculusData{ID: id, Item: metaapiwrapper.OculusItem{SKU: sku, ID: "item-" + sku}}
+	if granted != 0 {
+		data.GrantTime = entitlementNow.Add(granted).Unix()
+	}
+	if expires != 0 {
+		data.ExpirationTime = entitlementNow.Add(expires).Unix()
+	}
+	return data
+}
+
+func TestEntitlement(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      metaapiwrapper.OculusData
+		resolve   metaapiwrapper.KindResolver
+		kind      metaapiwrapper.EntitlementKind
+		active    bool
+		left      time.Duration
+		noExpiry  bool
+		grantZero bool
+	}{
+		{name: "durable", data: purchase("1", "sword", -time.Hour, 0), kind: metaapiwrapper.EntitlementDurable, active: true, noExpiry: true},
+		{name: "no grant time", data: purchase("1", "sword", 0, 0), kind: metaapiwrapper.EntitlementDurable, active: true, noExpiry: true, grantZero: true},
+		{name: "subscription", data: purchase("1", "vip", -time.Hour, time.Hour), kind: metaapiwrapper.EntitlementSubscription, active: true, left: time.Hour},
+		{name: "expired", data: purchase("1", "vip", -2*time.Hour, -time.Hour), kind: metaapiwrapper.EntitlementSubscription, left: -time.Hour},
+		{
+			name: "expires now",
+			data: metaapiwrapper.OculusData{ID: "1", GrantTime: entitlementNow.Add(-time.Hour).Unix(), ExpirationTime: entitlementNow.Unix(), Item: metaapiwrapper.OculusItem{SKU: "vip"}},
+			kind: metaapiwrapper.EntitlementSubscription,
+		},
+		{name: "future grant", data: purchase("1", "vip", time.Hour, 2*time.Hour), kind: metaapiwrapper.EntitlementSubscription, left: 2 * time.Hour},
+		{name: "future grant never expiring", data: purchase("1", "sword", time.Hour, 0), kind: metaapiwrapper.EntitlementDurable, noExpiry: true},
+		{
+			name:     "resolver overrides the heuristic",
+			data:     purchase("1", "gem", -time.Hour, 0),
+			resolve:  func(sku string) metaapiwrapper.EntitlementKind { return metaapiwrapper.EntitlementConsumable },
+			kind:     metaapiwrapper.EntitlementConsumable,
+			active:   true,
+			noExpiry: true,
+		},
+		{
+			name:    "resolver wins over an expiry",
+			data:    purchase("1", "pass", -time.Hour, time.Hour),
+			resolve: func(sku string) metaapiwrapper.EntitlementKind { return metaapiwrapper.EntitlementDurable },
+			kind:    metaapiwrapper.EntitlementDurable,
+			active:  true,
+			left:    time.Hour,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := tt.data.Entitlement(tt.resolve)
+			if e.ID != tt.data.ID || e.SKU != tt.data.Item.SKU || e.ItemID != tt.data.Item.ID {
+				t.Errorf("converted %+v from %+v", e, tt.data)
+			}
+			if e.GrantedAt.IsZero() != tt.grantZero {
+				t.Errorf("granted at %v", e.GrantedAt)
+			}
+			if e.Kind != tt.kind {
+				t.Errorf("kind %v, want %v", e.Kind, tt.kind)
+			}
+			if got := e.IsActive(entitlementNow); got != tt.active {
+				t.Errorf("active %v, want %v", got, tt.active)
+			}
+
+			left, expires := e.ExpiresIn(entitlementNow)
+			if expires == tt.noExpiry {
+				t.Errorf("expires %v, want %v", expires, !tt.noExpiry)
+			}
+			if left != tt.left {
+				t.Errorf("left %v, want %v", left, tt.left)
+			}
+		})
+	}
+}
+
+func TestNewEntitlementSet(t *testing.T) {
+	tests := []struct {
+		name   string
+		items  []metaapiwrapper.OculusData
+		wantID map[string]string
+		active []string
+	}{
+		{name: "empty", items: nil, wantID: map[string]string{}},
+		{
+			name:   "distinct SKUs",
+			items:  []metaapiwrapper.OculusData{purchase("1", "a", -time.Hour, 0), purchase("2", "b", -time.Hour, -time.Minute)},
+			wantID: map[string]string{"a": "1", "b": "2"},
+			active: []string{"a"},
+		},
+		{
+			name:   "never expiring outlasts expiring",
+			items:  []metaapiwrapper.OculusData{purchase("1", "a", -time.Hour, time.Hour), purchase("2", "a", -2*time.Hour, 0)},
+			wantID: map[string]string{"a": "2"},
+			active: []string{"a"},
+		},
+		{
+			name:   "never expiring kept when listed first",
+			items:  []metaapiwrapper.OculusData{purchase("1", "a", -2*time.Hour, 0), purchase("2", "a", -time.Hour, time.Hour)},
+			wantID: map[string]string{"a": "1"},
+			active: []string{"a"},
+		},
+		{
+			name:   "later expiry wins",
+			items:  []metaapiwrapper.OculusData{purchase("1", "a", -2*time.Hour, -time.Hour), purchase("2", "a", -time.Hour, time.Hour), purchase("3", "a", -time.Hour, time.Minute)},
+			wantID: map[string]string{"a": "2"},
+			active: []string{"a"},
+		},
+		{
+			name:   "same expiry, later grant wins",
+			items:  []metaapiwrapper.OculusData{purchase("1", "a", -time.Hour, time.Hour), purchase("2", "a", -time.Minute, time.Hour)},
+			wantID: map[string]string{"a": "2"},
+			active: []string{"a"},
+		},
+		{
+			name:   "identical records keep the first",
+			items:  []metaapiwrapper.OculusData{purchase("1", "a", -time.Hour, 0), purchase("2", "a", -time.Hour, 0)},
+			wantID: map[string]string{"a": "1"},
+			active: []string{"a"},
+		},
+		{
+			name:   "future grant is kept but inactive",
+			items:  []metaapiwrapper.OculusData{purchase("1", "a", -2*time.Hour, -time.Hour), purchase("2", "a", time.Hour, 2*time.Hour)},
+			wantID: map[string]string{"a": "2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := metaapiwrapper.NewEntitlementSet(tt.items, nil)
+			if len(set) != len(tt.wantID) {
+				t.Errorf("%v entitlements, want %v", len(set), len(tt.wantID))
+			}
+			for sku, id := range tt.wantID {
+				if set[sku].ID != id {
+					t.Errorf("%v kept record %v, want %v", sku, set[sku].ID, id)
+				}
+			}
+
+			active := set.Active(entitlementNow)
+			if len(active) != len(tt.active) {
+				t.Errorf("active %v, want %v", active, tt.active)
+			}
+			for _, sku := range tt.active {
+				if !set.Has(sku, entitlementNow) {
+					t.Errorf("%v is not active", sku)
+				}
+			}
+			if set.Has("missing", entitlementNow) {
+				t.Error("has a SKU never listed")
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package metaapiwrapper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrNonceInvalid is returned when Graph reports the user nonce as not valid
+	ErrNonceInvalid = errors.New("metaapiwrapper: user nonce invalid")
+	// ErrOrgScopedIDNotFound is returned when no org scoped ID can be resolved for the user
+	ErrOrgScopedIDNotFound = errors.New("metaapiwrapper: org scoped id not found")
+	// ErrEntitlementMissing is returned when the user does not own (or can no longer consume) the SKU
+	ErrEntitlementMissing = errors.New("metaapiwrapper: entitlement missing")
+)
+
+// MetaAPIError is the error object of a failed Graph call, along with the HTTP status it came with.
+// Every MetaApiRepository method returns it (possibly wrapped), so use errors.As or the Is* helpers below
+type MetaAPIError struct {
+	StatusCode int `json:"-"`
+
+	Message      string          `json:"message"`
+	Type         string          `json:"type"`
+	Code         int             `json:"code"`
+	ErrorSubcode int             `json:"error_subcode"`
+	FBTraceID    string          `json:"fbtrace_id"`
+	ErrorData    json.RawMessage `json:"error_data,omitempty"`
+
+	// RetryAfter is taken from the Retry-After header, 0 when absent
+	RetryAfter time.Duration `json:"-"`
+}
+
+func (e *MetaAPIError) Error() string {
+	return fmt.Sprintf("metaapiwrapper: graph error %v (subcode %v, http %v): %v", e.Code, e.ErrorSubcode, e.StatusCode, e.Message)
+}
+
+// Graph error codes, https://developers.facebook.com/docs/graph-api/guides/error-handling
+const (
+	graphCodeUnknown           = 1
+	graphCodeServiceDown       = 2
+	graphCodeInvalidParameter  = 100
+	graphCodeAccessToken       = 190
+	graphCodeSessionKeyInvalid = 102
+	graphCodeUserNotVisible    = 803
+
+	graphSubcodeObjectNotExist = 33
+)
+
+var graphRateLimitCodes = map[int]bool{
+	4:   true, // application request limit
+	17:  true, // user request limit
+	32:  true, // page request limit
+	613: true, // custom rate limit
+}
+
+// IsInvalidToken reports whether err is Graph rejecting the access token
+func IsInvalidToken(err error) bool {
+	var apiErr *MetaAPIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.Code == graphCodeAccessToken || apiErr.Code == graphCodeSessionKeyInvalid
+}
+
+// IsRateLimited reports whether err is Graph throttling the app or user
+func IsRateLimited(err error) bool {
+	var apiErr *MetaAPIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return graphRateLimitCodes[apiErr.Code] || apiErr.StatusCode == http.StatusTooManyRequests
+}
+
+// IsUserNotFound reports whether err is Graph not knowing (or not showing) the requested user
+func IsUserNotFound(err error) bool {
+	var apiErr *MetaAPIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return (apiErr.Code == graphCodeInvalidParameter && apiErr.ErrorSubcode == graphSubcodeObjectNotExist) || apiErr.Code == graphCodeUserNotVisible
+}
+
+// IsTransient reports whether err is worth retrying: throttling, Graph side failures and network timeouts.
+// A timeout of one attempt (http.Client.Timeout, dial or read deadlines) counts as transient; whether the caller's
+// own deadline has passed is up to the caller, see doJSONRetry
+func IsTransient(err error) bool {
+	if IsRateLimited(err) {
+		return true
+	}
+
+	var apiErr *MetaAPIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= http.StatusInternalServerError || apiErr.Code == graphCodeUnknown || apiErr.Code == graphCodeServiceDown
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// parseMetaAPIError picks the Graph error object out of respBytes; non-2xx responses without one still become a MetaAPIError
+func parseMetaAPIError(resp *http.Response, respBytes []byte) *MetaAPIError {
+	var envelope struct {
+		Error *MetaAPIError `json:"error"`
+	}
+	if err := json.Unmarshal(respBytes, &envelope); err == nil && envelope.Error != nil && (envelope.Error.Code != 0 || len(envelope.Error.Message) > 0) {
+		envelope.Error.StatusCode = resp.StatusCode
+		envelope.Error.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+		return envelope.Error
+	}
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return &MetaAPIError{
+			StatusCode: resp.StatusCode,
+			Message:    http.StatusText(resp.StatusCode),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+	return nil
+}
+
+// parseRetryAfter accepts both delay-seconds and HTTP-date forms
+func parseRetryAfter(value string) time.Duration {
+	if len(value) <= 0 {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if at, err := http.ParseTime(value); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
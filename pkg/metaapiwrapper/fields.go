@@ -0,0 +1,177 @@
+package metaapiwrapper
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnknownField is returned for field selections Graph would silently ignore
+var ErrUnknownField = errors.New("metaapiwrapper: unknown field")
+
+// Field is one entry of a Graph fields selection, either a plain field or a nested one such as item{sku,id}
+type Field string
+
+// Nested selects children of the object field name, e.g. Nested("item", ItemFieldSKU) is item{sku}
+func Nested(name string, children ...Field) Field {
+	if len(children) <= 0 {
+		return Field(name)
+	}
+	return Field(fmt.Sprintf("%v{%v}", name, strings.Join(Fields(children...), ",")))
+}
+
+// Fields converts typed fields to the Fields of a query
+func Fields(fields ...Field) []string {
+	out := make([]string, 0, len(fields))
+	for _, f := range fields {
+		out = append(out, string(f))
+	}
+	return out
+}
+
+// viewer_purchases fields, decoded into OculusData
+const (
+	PurchaseFieldID             Field = "id"
+	PurchaseFieldGrantTime      Field = "grant_time"
+	PurchaseFieldExpirationTime Field = "expiration_time"
+
+	// children of PurchaseItem, decoded into OculusItem
+	ItemFieldSKU Field = "sku"
+	ItemFieldID  Field = "id"
+)
+
+// PurchaseItem selects the item of a purchase, with all of its fields when none are given
+func PurchaseItem(fields ...Field) Field {
+	if len(fields) <= 0 {
+		fields = []Field{ItemFieldSKU, ItemFieldID}
+	}
+	return Nested("item", fields...)
+}
+
+// org scoped ID lookup fields, decoded into GetOculusOrgScopedIDResponse
+const (
+	UserFieldID          Field = "id"
+	UserFieldAlias       Field = "alias"
+	UserFieldOrgScopedID Field = "org_scoped_id"
+)
+
+var (
+	// DefaultPurchaseFields is requested when RetrieveItemsOwnedQuery.Fields is empty
+	DefaultPurchaseFields = []Field{PurchaseFieldID, PurchaseFieldGrantTime, PurchaseFieldExpirationTime, PurchaseItem()}
+	// DefaultOrgScopedIDFields is requested when GetOculusOrgScopedIDResponseQuery.Fields is empty
+	DefaultOrgScopedIDFields = []Field{UserFieldOrgScopedID}
+)
+
+// fieldSchema lists the known children of a field, nil for plain fields
+type fieldSchema map[string]fieldSchema
+
+var (
+	purchaseSchema = fieldSchema{
+		string(PurchaseFieldID):             nil,
+		string(PurchaseFieldGrantTime):      nil,
+		string(PurchaseFieldExpirationTime): nil,
+		"item": {
+			string(ItemFieldSKU): nil,
+			string(ItemFieldID):  nil,
+		},
+	}
+
+	orgScopedIDSchema = fieldSchema{
+		string(UserFieldID):          nil,
+		string(UserFieldAlias):       nil,
+		string(UserFieldOrgScopedID): nil,
+	}
+)
+
+// validate checks every selection of fields, e.g. ["id", "item{sku,id}"], against s
+func (s fieldSchema) validate(fields []string) error {
+	for _, f := range fields {
+		if err := s.validateSelection(f, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateSelection checks a comma separated selection; parent prefixes names in errors
+func (s fieldSchema) validateSelection(selection, parent string) error {
+	parts, err := splitSelection(selection)
+	if err != nil {
+		return err
+	}
+
+	for _, part := range parts {
+		name, children, nested := strings.Cut(part, "{")
+		name = strings.TrimSpace(name)
+
+		children, closed := strings.CutSuffix(strings.TrimSpace(children), "}")
+		if nested && !closed {
+			return fmt.Errorf("metaapiwrapper: unbalanced braces in %q", part)
+		}
+
+		sub, known := s[name]
+		if !known {
+			return fmt.Errorf("%w: %v%v", ErrUnknownField, parent, name)
+		}
+		if !nested {
+			continue
+		}
+		if sub == nil {
+			return fmt.Errorf("%w: %v%v has no nested fields", ErrUnknownField, parent, name)
+		}
+		if err = sub.validateSelection(children, parent+name+"."); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitSelection splits on the commas outside of braces
+func splitSelection(selection string) (parts []string, err error) {
+	depth, start := 0, 0
+	for i, r := range selection {
+		switch r {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("metaapiwrapper: unbalanced braces in %q", selection)
+			}
+		case ',':
+			if depth == 0 {
+				parts = append(parts, selection[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("metaapiwrapper: unbalanced braces in %q", selection)
+	}
+	parts = append(parts, selection[start:])
+
+	for _, part := range parts {
+		if len(strings.TrimSpace(part)) <= 0 {
+			return nil, fmt.Errorf("metaapiwrapper: empty field in %q", selection)
+		}
+	}
+	return
+}
+
+// fieldsOrDefault returns fields, or def when no field is selected
+func fieldsOrDefault(fields []string, def []Field) []string {
+	if len(fields) > 0 {
+		return fields
+	}
+	return Fields(def...)
+}
+
+// Validate reports selections of Fields that viewer_purchases does not know
+func (r *RetrieveItemsOwnedQuery) Validate() error {
+	return purchaseSchema.validate(r.Fields)
+}
+
+// Validate reports selections of Fields that the user node does not know
+func (r *GetOculusOrgScopedIDResponseQuery) Validate() error {
+	return orgScopedIDSchema.validate(r.Fields)
+}
@@ -0,0 +1,191 @@
+package metaapiwrapper
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestFieldsValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		fields   []string
+		org      bool
+		wantErr  error
+		errMatch string
+	}{
+		{name: "none selected", fields: nil},
+		{name: "defaults", fields: Fields(DefaultPurchaseFields...)},
+		{name: "plain", fields: []string{"id", "grant_time"}},
+		{name: "nested", fields: []string{"item{sku,id}"}},
+		{name: "one string", fields: []string{"id,item{sku},expiration_time"}},
+		{name: "spaces", fields: []string{" id , item { sku , id } "}},
+		{name: "builders", fields: Fields(PurchaseFieldID, PurchaseItem(ItemFieldSKU))},
+
+		{name: "unknown", fields: []string{"id", "skus"}, wantErr: ErrUnknownField, errMatch: "skus"},
+		{name: "unknown nested", fields: []string{"item{sku,price}"}, wantErr: ErrUnknownField, errMatch: "item.price"},
+		{name: "nested under a plain field", fields: []string{"id{sku}"}, wantErr: ErrUnknownField, errMatch: "id has no nested fields"},
+		{name: "child at the top", fields: []string{"sku"}, wantErr: ErrUnknownField, errMatch: "sku"},
+		{name: "wrong case", fields: []string{"ID"}, wantErr: ErrUnknownField, errMatch: "ID"},
+
+		{name: "unclosed", fields: []string{"item{sku"}, errMatch: "unbalanced braces"},
+		{name: "extra close", fields: []string{"item{sku}}"}, errMatch: "unbalanced braces"},
+		{name: "close first", fields: []string{"item}sku{"}, errMatch: "unbalanced braces"},
+		{name: "unclosed before a comma", fields: []string{"item{sku,id"}, errMatch: "unbalanced braces"},
+
+		{name: "empty string", fields: []string{""}, errMatch: "empty field"},
+		{name: "empty between commas", fields: []string{"id,,grant_time"}, errMatch: "empty field"},
+		{name: "trailing comma", fields: []string{"id,"}, errMatch: "empty field"},
+		{name: "empty nested", fields: []string{"item{}"}, errMatch: "empty field"},
+
+		{name: "org scoped", fields: Fields(UserFieldID, UserFieldAlias, UserFieldOrgScopedID), org: true},
+		{name: "org scoped defaults", fields: nil, org: true},
+		{name: "purchase field on a user", fields: []string{"item{sku}"}, org: true, wantErr: ErrUnknownField, errMatch: "item"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			if tt.org {
+				err = (&GetOculusOrgScopedIDResponseQuery{Fields: tt.fields}).Validate()
+			} else {
+				err = (&RetrieveItemsOwnedQuery{Fields: tt.fields}).Validate()
+			}
+
+			switch {
+			case tt.wantErr == nil && len(tt.errMatch) <= 0:
+				if err != nil {
+					t.Errorf("got %v, want valid", err)
+				}
+				return
+			case err == nil:
+				t.Fatalf("%q validated", tt.fields)
+			case tt.wantErr != nil && !errors.Is(err, tt.wantErr):
+				t.Errorf("got %v, want %v", err, tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.errMatch) {
+				t.Errorf("got %v, want it to mention %q", err, tt.errMatch)
+			}
+		})
+	}
+}
+
+func TestFieldBuilders(t *testing.T) {
+	tests := []struct {
+		name string
+		got  Field
+		want string
+	}{
+		{name: "nested", got: Nested("item", ItemFieldSKU), want: "item{sku}"},
+		{name: "nested without children", got: Nested("item"), want: "item"},
+		{name: "purchase item", got: PurchaseItem(ItemFieldID), want: "item{id}"},
+		{name: "purchase item defaults", got: PurchaseItem(), want: "item{sku,id}"},
+		{name: "nested twice", got: Nested("a", Nested("b", "c"), "d"), want: "a{b{c},d}"},
+	}
+	for _, tt := range tests {
+		if string(tt.got) != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestFieldsDefaultApplied(t *testing.T) {
+	cfg := OCULUSPlatformConfig{AppID: "app", AppSecret: "secret"}
+
+	purchases := []struct {
+		fields []string
+		want   string
+	}{
+		{fields: nil, want: "id,grant_time,expiration_time,item{sku,id}"},
+		{fields: []string{}, want: "id,grant_time,expiration_time,item{sku,id}"},
+		{fields: []string{"item{sku}"}, want: "item{sku}"},
+		{fields: []string{"id", "grant_time"}, want: "id,grant_time"},
+	}
+	for _, tt := range purchases {
+		q := RetrieveItemsOwnedQuery{OrgScopedID: "u1", Fields: tt.fields}
+		if got := q.BuildQuery(cfg).Get("fields"); got != tt.want {
+			t.Errorf("viewer_purchases %q: fields %q, want %q", tt.fields, got, tt.want)
+		}
+	}
+
+	users := []struct {
+		fields []string
+		want   string
+	}{
+		{fields: nil, want: "org_scoped_id"},
+		{fields: []string{"id", "alias"}, want: "id,alias"},
+	}
+	for _, tt := range users {
+		q := GetOculusOrgScopedIDResponseQuery{Fields: tt.fields}
+		if got := q.BuildQuery(cfg).Get("fields"); got != tt.want {
+			t.Errorf("org scoped ID %q: fields %q, want %q", tt.fields, got, tt.want)
+		}
+	}
+}
+
+// jsonFields maps the json names of the fields of typ to their types
+func jsonFields(typ reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < typ.NumField(); i++ {
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		if len(name) > 0 && name != "-" {
+			fields[name] = typ.Field(i).Type
+		}
+	}
+	return fields
+}
+
+// assertDecodable fails for fields of schema that typ has nowhere to decode into
+func assertDecodable(t *testing.T, schema fieldSchema, typ reflect.Type) {
+	t.Helper()
+
+	fields := jsonFields(typ)
+	for name, sub := range schema {
+		fieldType, ok := fields[name]
+		if !ok {
+			t.Errorf("%v has no field to decode the selectable %v into", typ.Name(), name)
+			continue
+		}
+		if sub != nil {
+			if fieldType.Kind() != reflect.Struct {
+				t.Errorf("%v.%v is nested but decodes into %v", typ.Name(), name, fieldType)
+				continue
+			}
+			assertDecodable(t, sub, fieldType)
+		}
+	}
+}
+
+// The response structs decode every field a selection may name, so a validated selection never comes back empty
+func TestFieldSchemasDecodable(t *testing.T) {
+	assertDecodable(t, purchaseSchema, reflect.TypeOf(OculusData{}))
+	assertDecodable(t, orgScopedIDSchema, reflect.TypeOf(GetOculusOrgScopedIDResponse{}))
+}
+
+func TestInvalidFieldsNotSent(t *testing.T) {
+	var calls atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer s.Close()
+
+	repo := NewMetaApiRepository(WithBaseURL(s.URL), WithPlatformConfig(OCULUSPlatformConfig{AppID: "app", AppSecret: "secret"}))
+	ctx := context.Background()
+
+	if _, err := repo.RequestOculusRetrieveItemsOwnedCtx(ctx, RetrieveItemsOwnedQuery{OrgScopedID: "u1", Fields: []string{"item{skus}"}}); !errors.Is(err, ErrUnknownField) {
+		t.Errorf("viewer_purchases: got %v, want ErrUnknownField", err)
+	}
+	if _, err := repo.GetOculusOrgScopedIDCtx(ctx, "u1", GetOculusOrgScopedIDResponseQuery{Fields: []string{"org_scopedid"}}); !errors.Is(err, ErrUnknownField) {
+		t.Errorf("org scoped ID: got %v, want ErrUnknownField", err)
+	}
+	if n := calls.Load(); n != 0 {
+		t.Errorf("%v requests sent", n)
+	}
+}
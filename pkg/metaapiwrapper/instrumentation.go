@@ -0,0 +1,118 @@
+package metaapiwrapper
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Endpoint names reported in CallInfo
+const (
+	EndpointUserNonceValidate  = "user_nonce_validate"
+	EndpointVerifyEntitlement  = "verify_entitlement"
+	EndpointConsumeEntitlement = "consume_entitlement"
+	EndpointViewerPurchases    = "viewer_purchases"
+	EndpointOrgScopedID        = "org_scoped_id"
+
+	EndpointAchievementDefinitions = "achievement_definitions"
+	EndpointUserAchievements       = "achievements"
+	EndpointWriteAchievement       = "achievements_write"
+
+	EndpointLeaderboards           = "leaderboards"
+	EndpointLeaderboardSubmitEntry = "leaderboard_submit_entry"
+	EndpointLeaderboardEntries     = "leaderboard_entries"
+	EndpointLeaderboardRemoveEntry = "leaderboard_remove_entry"
+
+	EndpointSubscriptions = "subscriptions"
+)
+
+// CallInfo describes a Graph call as it starts
+type CallInfo struct {
+	Endpoint string
+	AppID    string
+}
+
+// CallResult describes a finished Graph call, retries included
+type CallResult struct {
+	// StatusCode of the last response, 0 when none was received
+	StatusCode int
+	// Graph error details when Err is a *MetaAPIError
+	GraphCode    int
+	GraphSubcode int
+	FBTraceID    string
+
+	Attempts int
+	Duration time.Duration
+	Err      error
+}
+
+// Instrumentation observes every Graph call of a repository, e.g. to emit spans and metrics.
+// StartCall may return a derived ctx (carrying a span) which is used for the outgoing request; end is called exactly once
+type Instrumentation interface {
+	StartCall(ctx context.Context, info CallInfo) (callCtx context.Context, end func(result CallResult))
+}
+
+type noopInstrumentation struct{}
+
+func (noopInstrumentation) StartCall(ctx context.Context, _ CallInfo) (context.Context, func(CallResult)) {
+	return ctx, func(CallResult) {}
+}
+
+// WithInstrumentation reports every Graph call to instrumentation
+func WithInstrumentation(instrumentation Instrumentation) Option {
+	return func(m *metaApiRepositoryImpl) {
+		m.instrumentation = instrumentation
+	}
+}
+
+// call sends req for endpoint and decodes the response into out, retrying when idempotent, under instrumentation
+func (m *metaApiRepositoryImpl) call(endpoint string, req *http.Request, out interface{}, idempotent bool) (err error) {
+	instrumentation := m.instrumentation
+	if instrumentation == nil {
+		instrumentation = noopInstrumentation{}
+	}
+
+	ctx, end := instrumentation.StartCall(req.Context(), CallInfo{
+		Endpoint: endpoint,
+		AppID:    m.AccessToken.AppID,
+	})
+	req = req.WithContext(ctx)
+
+	start := time.Now()
+	result := CallResult{Attempts: 1}
+	if m.breakers == nil {
+		result.StatusCode, result.Attempts, err = m.send(req, out, idempotent)
+	} else {
+		var report func(err error)
+		if report, err = m.breakers.allow(endpoint); err != nil {
+			result.Attempts = 0
+		} else {
+			result.StatusCode, result.Attempts, err = m.send(req, out, idempotent)
+			report(err)
+		}
+	}
+
+	result.Duration = time.Since(start)
+	result.Err = err
+
+	var apiErr *MetaAPIError
+	if errors.As(err, &apiErr) {
+		result.GraphCode = apiErr.Code
+		result.GraphSubcode = apiErr.ErrorSubcode
+		result.FBTraceID = apiErr.FBTraceID
+	}
+
+	end(result)
+	return
+}
+
+// send runs req once, or under the retry policy when idempotent
+func (m *metaApiRepositoryImpl) send(req *http.Request, out interface{}, idempotent bool) (status int, attempts int, err error) {
+	if idempotent {
+		return m.doJSONRetry(req, out)
+	}
+
+	status, err = m.doJSON(req, out)
+	return status, 1, err
+}
@@ -0,0 +1,158 @@
+package metaapiwrapper_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperbting/api-library/pkg/metaapiwrapper"
+	"github.com/hyperbting/api-library/pkg/metaapiwrapper/metaapitest"
+)
+
+type callCtxKey struct{}
+
+// recordedCall is one StartCall with every end it got
+type recordedCall struct {
+	info metaapiwrapper.CallInfo
+	ends []metaapiwrapper.CallResult
+}
+
+// recordingInstrumentation keeps every call and tags the call ctx so that the request can be matched to it
+type recordingInstrumentation struct {
+	mu    sync.Mutex
+	calls []*recordedCall
+}
+
+func (r *recordingInstrumentation) StartCall(ctx context.Context, info metaapiwrapper.CallInfo) (context.Context, func(metaapiwrapper.CallResult)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	call := &recordedCall{info: info}
+	r.calls = append(r.calls, call)
+	return context.WithValue(ctx, callCtxKey{}, info.Endpoint), func(result metaapiwrapper.CallResult) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		call.ends = append(call.ends, result)
+	}
+}
+
+// take returns the calls recorded so far and forgets them
+func (r *recordingInstrumentation) take() []*recordedCall {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	calls := r.calls
+	r.calls = nil
+	return calls
+}
+
+// callCtxTransport fails requests that do not carry the ctx returned by StartCall
+type callCtxTransport struct {
+	next http.RoundTripper
+}
+
+func (t callCtxTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if _, ok := req.Context().Value(callCtxKey{}).(string); !ok {
+		return nil, errors.New("request sent without the instrumentation ctx")
+	}
+	return t.next.RoundTrip(req)
+}
+
+func TestInstrumentation(t *testing.T) {
+	s := metaapitest.NewServer("app", "secret")
+	defer s.Close()
+	s.AddEntitlement("u1", metaapitest.Entitlement{SKU: "sku"})
+
+	var rec recordingInstrumentation
+	repo := metaapiwrapper.NewMetaApiRepository(append(s.Options(),
+		metaapiwrapper.WithRoundTripper(callCtxTransport{next: s.Client().Transport}),
+		metaapiwrapper.WithRetryPolicy(testRetryPolicy),
+		metaapiwrapper.WithCircuitBreaker(metaapiwrapper.BreakerConfig{FailureThreshold: 1, CoolDown: time.Hour}),
+		metaapiwrapper.WithInstrumentation(&rec))...)
+	ctx := context.Background()
+	verify := func() error {
+		_, err := repo.RequestOculusVerifyItemOwnershipCtx(ctx, metaapiwrapper.VerifyItemOwnershipQuery{UsrID: "u1", SKU: "sku"})
+		return err
+	}
+
+	tests := []struct {
+		name    string
+		prepare func()
+		want    metaapiwrapper.CallResult
+		wantErr error
+	}{
+		{
+			name: "success",
+			want: metaapiwrapper.CallResult{StatusCode: http.StatusOK, Attempts: 1},
+		},
+		{
+			name: "retried",
+			prepare: func() {
+				s.InjectError(metaapitest.EndpointVerifyEntitlement, graphDown, 1)
+			},
+			want: metaapiwrapper.CallResult{StatusCode: http.StatusOK, Attempts: 2},
+		},
+		{
+			name: "graph error",
+			prepare: func() {
+				s.InjectError(metaapitest.EndpointVerifyEntitlement, metaapiwrapper.MetaAPIError{StatusCode: http.StatusBadRequest, Code: 100, ErrorSubcode: 33, FBTraceID: "trace-1", Message: "bad sku"}, 1)
+			},
+			want: metaapiwrapper.CallResult{StatusCode: http.StatusBadRequest, Attempts: 1, GraphCode: 100, GraphSubcode: 33, FBTraceID: "trace-1"},
+		},
+		{
+			name: "opens the circuit",
+			prepare: func() {
+				s.InjectError(metaapitest.EndpointVerifyEntitlement, graphDown, 0)
+			},
+			want: metaapiwrapper.CallResult{StatusCode: http.StatusServiceUnavailable, Attempts: 3, GraphCode: 2},
+		},
+		{
+			name:    "short circuited",
+			want:    metaapiwrapper.CallResult{},
+			wantErr: metaapiwrapper.ErrCircuitOpen,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepare != nil {
+				tt.prepare()
+			}
+			err := verify()
+
+			calls := rec.take()
+			if len(calls) != 1 {
+				t.Fatalf("%v calls recorded, want 1", len(calls))
+			}
+			call := calls[0]
+			if want := (metaapiwrapper.CallInfo{Endpoint: metaapiwrapper.EndpointVerifyEntitlement, AppID: "app"}); call.info != want {
+				t.Errorf("info %+v, want %+v", call.info, want)
+			}
+			if len(call.ends) != 1 {
+				t.Fatalf("end called %v times, want once", len(call.ends))
+			}
+
+			got := call.ends[0]
+			if got.Err != err {
+				t.Errorf("result error %v, the call returned %v", got.Err, err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("got %v, want %v", err, tt.wantErr)
+			}
+			if tt.want.GraphCode == 0 && tt.wantErr == nil && err != nil {
+				t.Errorf("unexpected error %v", err)
+			}
+			if got.Duration <= 0 && tt.want.Attempts > 0 {
+				t.Errorf("duration %v", got.Duration)
+			}
+			got.Err, got.Duration = nil, 0
+			if got != tt.want {
+				t.Errorf("result %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
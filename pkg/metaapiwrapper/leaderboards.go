@@ -0,0 +1,257 @@
+package metaapiwrapper
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+//https://developer.oculus.com/documentation/unity/ps-leaderboards-s2s/
+
+// ErrLeaderboardEntryRejected is returned when Graph answers a submission or a removal without success
+var ErrLeaderboardEntryRejected = errors.New("metaapiwrapper: leaderboard entry rejected")
+
+const (
+	LeaderboardFilterNone    = "NONE"
+	LeaderboardFilterFriends = "FRIENDS"
+	LeaderboardFilterUserIDs = "USER_IDS"
+
+	LeaderboardStartAtTop              = "TOP"
+	LeaderboardStartAtCenteredOnViewer = "CENTERED_ON_VIEWER"
+)
+
+type LeaderboardsQuery struct {
+	// APIName limits the result to one leaderboard, all of them when empty
+	APIName string   `json:"api_name"`
+	Fields  []string `json:"fields"`
+	After   string   `json:"after,omitempty"`
+}
+
+func (l *LeaderboardsQuery) BuildQuery(cfg OCULUSPlatformConfig) url.Values {
+	params := url.Values{}
+	params.Add("access_token", cfg.FormAccessToken())
+	if len(l.APIName) > 0 {
+		params.Add("api_name", l.APIName)
+	}
+	if len(l.Fields) > 0 {
+		params.Add("fields", strings.Join(l.Fields, ","))
+	}
+	if len(l.After) > 0 {
+		params.Add("after", l.After)
+	}
+
+	return params
+}
+
+type Leaderboard struct {
+	ID                       string `json:"id"`
+	APIName                  string `json:"api_name"`
+	SortOrder                string `json:"sort_order"`
+	EntryWritePolicy         string `json:"entry_write_policy"`
+	EarliestAllowedEntryTime int64  `json:"earliest_allowed_entry_time"`
+	LatestAllowedEntryTime   int64  `json:"latest_allowed_entry_time"`
+}
+
+type LeaderboardsResponse struct {
+	Data   []Leaderboard `json:"data"`
+	Paging OculusPaging  `json:"paging"`
+}
+
+func (m *metaApiRepositoryImpl) RequestOculusLeaderboards(q LeaderboardsQuery) (oculusResp LeaderboardsResponse, err error) {
+	return m.RequestOculusLeaderboardsCtx(context.Background(), q)
+}
+
+func (m *metaApiRepositoryImpl) RequestOculusLeaderboardsCtx(ctx context.Context, q LeaderboardsQuery) (oculusResp LeaderboardsResponse, err error) {
+	var req *http.Request
+
+	if req, err = m.newEndpointRequest(ctx, EndpointLeaderboards, "", q.BuildQuery(m.AccessToken)); err != nil {
+		return
+	}
+
+	// Send request
+	err = m.call(EndpointLeaderboards, req, &oculusResp, true)
+
+	return
+}
+
+// SubmitLeaderboardEntryQuery writes Score for UserID. Graph keeps the better score unless ForceUpdate is set
+type SubmitLeaderboardEntryQuery struct {
+	APIName     string `json:"api_name"`
+	UserID      string `json:"user_id"`
+	Score       int64  `json:"score"`
+	ExtraData   []byte `json:"extra_data,omitempty"`
+	ForceUpdate bool   `json:"force_update,omitempty"`
+}
+
+func (s *SubmitLeaderboardEntryQuery) BuildQuery(cfg OCULUSPlatformConfig) url.Values {
+	params := url.Values{}
+	params.Add("access_token", cfg.FormAccessToken())
+	params.Add("api_name", s.APIName)
+	params.Add("user_id", s.UserID)
+	params.Add("score", strconv.FormatInt(s.Score, 10))
+	if len(s.ExtraData) > 0 {
+		params.Add("extra_data_base64", base64.StdEncoding.EncodeToString(s.ExtraData))
+	}
+	if s.ForceUpdate {
+		params.Add("force_update", "true")
+	}
+
+	return params
+}
+
+type SubmitLeaderboardEntryResponse struct {
+	Success   bool `json:"success"`
+	DidUpdate bool `json:"did_update"`
+}
+
+func (m *metaApiRepositoryImpl) RequestOculusSubmitLeaderboardEntry(q SubmitLeaderboardEntryQuery) (oculusResp SubmitLeaderboardEntryResponse, err error) {
+	return m.RequestOculusSubmitLeaderboardEntryCtx(context.Background(), q)
+}
+
+// RequestOculusSubmitLeaderboardEntryCtx is server authoritative score submission; it is not retried
+func (m *metaApiRepositoryImpl) RequestOculusSubmitLeaderboardEntryCtx(ctx context.Context, q SubmitLeaderboardEntryQuery) (oculusResp SubmitLeaderboardEntryResponse, err error) {
+	var req *http.Request
+
+	if req, err = m.newEndpointRequest(ctx, EndpointLeaderboardSubmitEntry, "", q.BuildQuery(m.AccessToken)); err != nil {
+		return
+	}
+
+	// Send request
+	if err = m.call(EndpointLeaderboardSubmitEntry, req, &oculusResp, false); err != nil {
+		return
+	}
+
+	if !oculusResp.Success {
+		err = ErrLeaderboardEntryRejected
+	}
+	return
+}
+
+// LeaderboardEntriesQuery pages through a leaderboard; top-N with StartAt TOP and Limit N,
+// or the entries around UserID with StartAt CENTERED_ON_VIEWER
+type LeaderboardEntriesQuery struct {
+	APIName string   `json:"api_name"`
+	Filter  string   `json:"filter"`
+	StartAt string   `json:"start_at"`
+	UserID  string   `json:"user_id,omitempty"`
+	UserIDs []string `json:"user_ids,omitempty"`
+	Limit   int      `json:"limit,omitempty"`
+	Fields  []string `json:"fields"`
+	After   string   `json:"after,omitempty"`
+}
+
+func (l *LeaderboardEntriesQuery) BuildQuery(cfg OCULUSPlatformConfig) url.Values {
+	params := url.Values{}
+	params.Add("access_token", cfg.FormAccessToken())
+	params.Add("api_name", l.APIName)
+
+	filter := l.Filter
+	if len(filter) <= 0 {
+		filter = LeaderboardFilterNone
+	}
+	params.Add("filter", filter)
+
+	if len(l.StartAt) > 0 {
+		params.Add("start_at", l.StartAt)
+	}
+	if len(l.UserID) > 0 {
+		params.Add("user_id", l.UserID)
+	}
+	if len(l.UserIDs) > 0 {
+		params.Add("user_ids", jsonArrayParam(l.UserIDs))
+	}
+	if l.Limit > 0 {
+		params.Add("limit", strconv.Itoa(l.Limit))
+	}
+	if len(l.Fields) > 0 {
+		params.Add("fields", strings.Join(l.Fields, ","))
+	}
+	if len(l.After) > 0 {
+		params.Add("after", l.After)
+	}
+
+	return params
+}
+
+type LeaderboardUser struct {
+	ID    string `json:"id"`
+	Alias string `json:"alias"`
+}
+
+type LeaderboardEntry struct {
+	ID              string          `json:"id"`
+	Rank            int64           `json:"rank"`
+	Score           int64           `json:"score"`
+	Timestamp       int64           `json:"timestamp"`
+	ExtraDataBase64 string          `json:"extra_data_base64"`
+	User            LeaderboardUser `json:"user"`
+}
+
+// ExtraData decodes ExtraDataBase64
+func (l *LeaderboardEntry) ExtraData() ([]byte, error) {
+	return base64.StdEncoding.DecodeString(l.ExtraDataBase64)
+}
+
+type LeaderboardEntriesResponse struct {
+	Data   []LeaderboardEntry `json:"data"`
+	Paging OculusPaging       `json:"paging"`
+}
+
+func (m *metaApiRepositoryImpl) RequestOculusLeaderboardEntries(q LeaderboardEntriesQuery) (oculusResp LeaderboardEntriesResponse, err error) {
+	return m.RequestOculusLeaderboardEntriesCtx(context.Background(), q)
+}
+
+// RequestOculusLeaderboardEntriesCtx returns one page; continue with q.After = Paging.Cursors.After while Paging.Next is set
+func (m *metaApiRepositoryImpl) RequestOculusLeaderboardEntriesCtx(ctx context.Context, q LeaderboardEntriesQuery) (oculusResp LeaderboardEntriesResponse, err error) {
+	var req *http.Request
+
+	if req, err = m.newEndpointRequest(ctx, EndpointLeaderboardEntries, "", q.BuildQuery(m.AccessToken)); err != nil {
+		return
+	}
+
+	// Send request
+	err = m.call(EndpointLeaderboardEntries, req, &oculusResp, true)
+
+	return
+}
+
+type RemoveLeaderboardEntryQuery struct {
+	APIName string `json:"api_name"`
+	UserID  string `json:"user_id"`
+}
+
+func (r *RemoveLeaderboardEntryQuery) BuildQuery(cfg OCULUSPlatformConfig) url.Values {
+	params := url.Values{}
+	params.Add("access_token", cfg.FormAccessToken())
+	params.Add("api_name", r.APIName)
+	params.Add("user_id", r.UserID)
+
+	return params
+}
+
+func (m *metaApiRepositoryImpl) RequestOculusRemoveLeaderboardEntry(q RemoveLeaderboardEntryQuery) (OculusResp OCULUSResponseBase, err error) {
+	return m.RequestOculusRemoveLeaderboardEntryCtx(context.Background(), q)
+}
+
+// RequestOculusRemoveLeaderboardEntryCtx deletes the entry of q.UserID; ErrLeaderboardEntryRejected when Graph does not confirm it
+func (m *metaApiRepositoryImpl) RequestOculusRemoveLeaderboardEntryCtx(ctx context.Context, q RemoveLeaderboardEntryQuery) (OculusResp OCULUSResponseBase, err error) {
+	var req *http.Request
+
+	if req, err = m.newEndpointRequest(ctx, EndpointLeaderboardRemoveEntry, "", q.BuildQuery(m.AccessToken)); err != nil {
+		return
+	}
+
+	// Send request
+	if err = m.call(EndpointLeaderboardRemoveEntry, req, &OculusResp, true); err != nil {
+		return
+	}
+
+	if !OculusResp.Success {
+		err = ErrLeaderboardEntryRejected
+	}
+	return
+}
@@ -0,0 +1,32 @@
+package metaapiwrapper_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hyperbting/api-library/pkg/metaapiwrapper"
+)
+
+func TestLeaderboardEntryRejected(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"success":false}`))
+	}))
+	defer s.Close()
+
+	repo := metaapiwrapper.NewMetaApiRepository(
+		metaapiwrapper.WithBaseURL(s.URL),
+		metaapiwrapper.WithPlatformConfig(metaapiwrapper.OCULUSPlatformConfig{AppID: "app", AppSecret: "secret"}),
+	)
+	ctx := context.Background()
+
+	if _, err := repo.RequestOculusSubmitLeaderboardEntryCtx(ctx, metaapiwrapper.SubmitLeaderboardEntryQuery{APIName: "board", UserID: "u1", Score: 1}); !errors.Is(err, metaapiwrapper.ErrLeaderboardEntryRejected) {
+		t.Errorf("submit: got %v, want ErrLeaderboardEntryRejected", err)
+	}
+	if _, err := repo.RequestOculusRemoveLeaderboardEntryCtx(ctx, metaapiwrapper.RemoveLeaderboardEntryQuery{APIName: "board", UserID: "u1"}); !errors.Is(err, metaapiwrapper.ErrLeaderboardEntryRejected) {
+		t.Errorf("remove: got %v, want ErrLeaderboardEntryRejected", err)
+	}
+}
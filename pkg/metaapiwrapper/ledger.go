@@ -0,0 +1,328 @@
+package metaapiwrapper
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+)
+
+const (
+	ledgerCompleteAttempts = 3
+	ledgerCompleteBackoff  = 100 * time.Millisecond
+)
+
+// DefaultConsumptionPendingTTL is how long a pending reservation blocks its key before it may be reclaimed
+const DefaultConsumptionPendingTTL = 5 * time.Minute
+
+var (
+	// ErrConsumptionInProgress is returned when another request holds the same idempotency key
+	ErrConsumptionInProgress = errors.New("metaapiwrapper: consumption in progress")
+	// ErrIdempotencyKeyReused is returned when an idempotency key is replayed for another user or SKU
+	ErrIdempotencyKeyReused = errors.New("metaapiwrapper: idempotency key reused")
+	// ErrConsumptionUnrecorded is returned together with a successful response when Graph consumed the item
+	// but the ledger could not record it; the item is gone, grant it
+	ErrConsumptionUnrecorded = errors.New("metaapiwrapper: consumption not recorded")
+	// ErrConsumptionOutcomeUnknown is returned when Graph may have consumed the item without confirming it, e.g. after a timeout or a 5xx.
+	// The key stays pending, so retries with it never consume a second unit; reconcile before granting or refunding
+	ErrConsumptionOutcomeUnknown = errors.New("metaapiwrapper: consumption outcome unknown")
+	// ErrInvalidTableName is returned by the SQL stores for table names that are not plain identifiers
+	ErrInvalidTableName = errors.New("metaapiwrapper: invalid table name")
+
+	// table names are interpolated into SQL, so only [schema.]identifier is accepted
+	tableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+)
+
+func validateTableName(table string) error {
+	if !tableNamePattern.MatchString(table) {
+		return fmt.Errorf("%w: %q", ErrInvalidTableName, table)
+	}
+	return nil
+}
+
+type ConsumptionState int
+
+const (
+	ConsumptionPending ConsumptionState = iota
+	ConsumptionConsumed
+)
+
+// ConsumptionRecord is what a ConsumptionLedger keeps per idempotency key
+type ConsumptionRecord struct {
+	IdempotencyKey string
+	UserID         string
+	SKU            string
+	State          ConsumptionState
+	RecordedAt     time.Time
+}
+
+// ConsumptionLedger records consume_entitlement calls by idempotency key
+type ConsumptionLedger interface {
+	// Reserve stores rec as pending unless its key is known, in which case the known record is returned and reserved is false
+	Reserve(ctx context.Context, rec ConsumptionRecord) (existing ConsumptionRecord, reserved bool, err error)
+	// Reclaim takes over the pending reservation of rec.IdempotencyKey if it was recorded before staleBefore,
+	// e.g. left behind by a crashed process; only one caller can win a given stale reservation
+	Reclaim(ctx context.Context, rec ConsumptionRecord, staleBefore time.Time) (reclaimed bool, err error)
+	// Complete marks a reserved key as consumed
+	Complete(ctx context.Context, idempotencyKey string) error
+	// Release drops a pending reservation so the key can be retried after Graph rejected the consumption
+	Release(ctx context.Context, idempotencyKey string) error
+}
+
+// WithConsumptionLedger makes RequestOculusConsumeIAPItem idempotent per OculusConsumeIAPItemQuery.IdempotencyKey
+func WithConsumptionLedger(ledger ConsumptionLedger) Option {
+	return func(m *metaApiRepositoryImpl) {
+		m.ledger = ledger
+	}
+}
+
+// WithConsumptionPendingTTL sets how long a pending reservation is honoured before another request may reclaim it,
+// DefaultConsumptionPendingTTL when 0. Keep it well above the time a consume_entitlement call can take
+func WithConsumptionPendingTTL(ttl time.Duration) Option {
+	return func(m *metaApiRepositoryImpl) {
+		m.ledgerPendingTTL = ttl
+	}
+}
+
+// consumeWithLedger consumes once per idempotency key; a replayed key that was consumed already reports success without calling Graph.
+// The key is released only when Graph provably did not consume; any other failure keeps it pending with ErrConsumptionOutcomeUnknown.
+// A reservation left pending for longer than the pending TTL is reclaimed, and consumed only if verify_entitlement still reports the item
+func (m *metaApiRepositoryImpl) consumeWithLedger(ctx context.Context, q OculusConsumeIAPItemQuery) (OculusResp OCULUSResponseBase, err error) {
+	rec := ConsumptionRecord{
+		IdempotencyKey: q.IdempotencyKey,
+		UserID:         q.UsrID,
+		SKU:            q.SKU,
+		State:          ConsumptionPending,
+		RecordedAt:     time.Now(),
+	}
+
+	existing, reserved, err := m.ledger.Reserve(ctx, rec)
+	if err != nil {
+		return
+	}
+
+	if !reserved {
+		if existing.UserID != rec.UserID || existing.SKU != rec.SKU {
+			err = ErrIdempotencyKeyReused
+			return
+		}
+
+		if existing.State == ConsumptionConsumed {
+			OculusResp.Success = true
+			return
+		}
+
+		ttl := m.ledgerPendingTTL
+		if ttl <= 0 {
+			ttl = DefaultConsumptionPendingTTL
+		}
+		if staleBefore := rec.RecordedAt.Add(-ttl); existing.RecordedAt.Before(staleBefore) {
+			if reserved, err = m.ledger.Reclaim(ctx, rec, staleBefore); err != nil {
+				return
+			}
+		}
+
+		if !reserved {
+			err = ErrConsumptionInProgress
+			return
+		}
+
+		// the earlier attempt may have consumed the item and lost the answer; a missing item cannot tell which
+		if _, err = m.RequestOculusVerifyItemOwnershipCtx(ctx, q.VerifyItemOwnershipQuery); err != nil {
+			if errors.Is(err, ErrEntitlementMissing) {
+				err = fmt.Errorf("%w: %w", ErrConsumptionOutcomeUnknown, err)
+			}
+			return
+		}
+	}
+
+	if OculusResp, err = m.consumeIAPItem(ctx, q); err != nil {
+		if !consumeNotApplied(err) {
+			err = fmt.Errorf("%w: %w", ErrConsumptionOutcomeUnknown, err)
+			return
+		}
+
+		// use a fresh context in case ctx is what failed
+		if releaseErr := m.ledger.Release(context.WithoutCancel(ctx), q.IdempotencyKey); releaseErr != nil {
+			err = errors.Join(err, releaseErr)
+		}
+		return
+	}
+
+	// the item is consumed now, try hard to record it; if that still fails the reservation stays pending until the pending TTL reclaims it
+	completeCtx := context.WithoutCancel(ctx)
+	for attempt := 1; ; attempt++ {
+		completeErr := m.ledger.Complete(completeCtx, q.IdempotencyKey)
+		if completeErr == nil {
+			return
+		}
+		if attempt >= ledgerCompleteAttempts {
+			err = fmt.Errorf("%w: %w", ErrConsumptionUnrecorded, completeErr)
+			return
+		}
+		time.Sleep(time.Duration(attempt) * ledgerCompleteBackoff)
+	}
+}
+
+// consumeNotApplied reports whether err proves that Graph did not consume: it rejected the call, or the call was never sent
+func consumeNotApplied(err error) bool {
+	if errors.Is(err, ErrEntitlementMissing) || errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrRateLimited) || errors.Is(err, ErrInvalidAPIVersion) {
+		return true
+	}
+
+	var apiErr *MetaAPIError
+	return errors.As(err, &apiErr) && !IsTransient(err)
+}
+
+// MemoryConsumptionLedger guards idempotency keys within one process; the keys are lost on restart,
+// so a retry arriving after a restart consumes again
+type MemoryConsumptionLedger struct {
+	mu      sync.Mutex
+	records map[string]ConsumptionRecord
+}
+
+func NewMemoryConsumptionLedger() *MemoryConsumptionLedger {
+	return &MemoryConsumptionLedger{
+		records: map[string]ConsumptionRecord{},
+	}
+}
+
+func (l *MemoryConsumptionLedger) Reserve(_ context.Context, rec ConsumptionRecord) (existing ConsumptionRecord, reserved bool, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if existing, ok := l.records[rec.IdempotencyKey]; ok {
+		return existing, false, nil
+	}
+
+	l.records[rec.IdempotencyKey] = rec
+	return rec, true, nil
+}
+
+func (l *MemoryConsumptionLedger) Reclaim(_ context.Context, rec ConsumptionRecord, staleBefore time.Time) (reclaimed bool, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	existing, ok := l.records[rec.IdempotencyKey]
+	if !ok || existing.State != ConsumptionPending || !existing.RecordedAt.Before(staleBefore) {
+		return false, nil
+	}
+
+	l.records[rec.IdempotencyKey] = rec
+	return true, nil
+}
+
+func (l *MemoryConsumptionLedger) Complete(_ context.Context, idempotencyKey string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	rec, ok := l.records[idempotencyKey]
+	if !ok {
+		return fmt.Errorf("metaapiwrapper: no reservation for %v", idempotencyKey)
+	}
+
+	rec.State = ConsumptionConsumed
+	rec.RecordedAt = time.Now()
+	l.records[idempotencyKey] = rec
+	return nil
+}
+
+func (l *MemoryConsumptionLedger) Release(_ context.Context, idempotencyKey string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if rec, ok := l.records[idempotencyKey]; ok && rec.State == ConsumptionPending {
+		delete(l.records, idempotencyKey)
+	}
+	return nil
+}
+
+// ConsumptionLedgerSchema creates the table used by SQLConsumptionLedger (MySQL, as opened by dbhelper); %v is the table name
+const ConsumptionLedgerSchema = `CREATE TABLE IF NOT EXISTS %v (
+	idempotency_key VARCHAR(191) NOT NULL PRIMARY KEY,
+	user_id         VARCHAR(64)  NOT NULL,
+	sku             VARCHAR(191) NOT NULL,
+	state           TINYINT      NOT NULL,
+	recorded_at     BIGINT       NOT NULL
+)`
+
+// SQLConsumptionLedger guards idempotency keys in a MySQL table, across restarts and across every instance consuming for the app
+type SQLConsumptionLedger struct {
+	db    *sql.DB
+	table string
+}
+
+// NewSQLConsumptionLedger stores the ledger in table of db, typically dbhelper.GetDB();
+// table must be a plain [schema.]name, ErrInvalidTableName otherwise
+func NewSQLConsumptionLedger(db *sql.DB, table string) (*SQLConsumptionLedger, error) {
+	if err := validateTableName(table); err != nil {
+		return nil, err
+	}
+
+	return &SQLConsumptionLedger{
+		db:    db,
+		table: table,
+	}, nil
+}
+
+// CreateTable creates the ledger table if it does not exist
+func (l *SQLConsumptionLedger) CreateTable(ctx context.Context) (err error) {
+	_, err = l.db.ExecContext(ctx, fmt.Sprintf(ConsumptionLedgerSchema, l.table))
+	return
+}
+
+func (l *SQLConsumptionLedger) Reserve(ctx context.Context, rec ConsumptionRecord) (existing ConsumptionRecord, reserved bool, err error) {
+	var res sql.Result
+	if res, err = l.db.ExecContext(ctx,
+		fmt.Sprintf("INSERT IGNORE INTO %v (idempotency_key, user_id, sku, state, recorded_at) VALUES (?, ?, ?, ?, ?)", l.table),
+		rec.IdempotencyKey, rec.UserID, rec.SKU, rec.State, rec.RecordedAt.UnixMilli()); err != nil {
+		return
+	}
+
+	var affected int64
+	if affected, err = res.RowsAffected(); err != nil {
+		return
+	}
+	if affected > 0 {
+		return rec, true, nil
+	}
+
+	var recordedAt int64
+	err = l.db.QueryRowContext(ctx,
+		fmt.Sprintf("SELECT idempotency_key, user_id, sku, state, recorded_at FROM %v WHERE idempotency_key = ?", l.table),
+		rec.IdempotencyKey).Scan(&existing.IdempotencyKey, &existing.UserID, &existing.SKU, &existing.State, &recordedAt)
+	existing.RecordedAt = time.UnixMilli(recordedAt)
+	return
+}
+
+func (l *SQLConsumptionLedger) Reclaim(ctx context.Context, rec ConsumptionRecord, staleBefore time.Time) (reclaimed bool, err error) {
+	var res sql.Result
+	if res, err = l.db.ExecContext(ctx,
+		fmt.Sprintf("UPDATE %v SET recorded_at = ? WHERE idempotency_key = ? AND state = ? AND recorded_at < ?", l.table),
+		rec.RecordedAt.UnixMilli(), rec.IdempotencyKey, ConsumptionPending, staleBefore.UnixMilli()); err != nil {
+		return
+	}
+
+	var affected int64
+	if affected, err = res.RowsAffected(); err != nil {
+		return
+	}
+	return affected > 0, nil
+}
+
+func (l *SQLConsumptionLedger) Complete(ctx context.Context, idempotencyKey string) (err error) {
+	_, err = l.db.ExecContext(ctx,
+		fmt.Sprintf("UPDATE %v SET state = ?, recorded_at = ? WHERE idempotency_key = ?", l.table),
+		ConsumptionConsumed, time.Now().UnixMilli(), idempotencyKey)
+	return
+}
+
+func (l *SQLConsumptionLedger) Release(ctx context.Context, idempotencyKey string) (err error) {
+	_, err = l.db.ExecContext(ctx,
+		fmt.Sprintf("DELETE FROM %v WHERE idempotency_key = ? AND state = ?", l.table),
+		idempotencyKey, ConsumptionPending)
+	return
+}
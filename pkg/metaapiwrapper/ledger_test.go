@@ -0,0 +1,212 @@
+package metaapiwrapper_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperbting/api-library/pkg/metaapiwrapper"
+	"github.com/hyperbting/api-library/pkg/metaapiwrapper/metaapitest"
+)
+
+func consumeQuery(key string) metaapiwrapper.OculusConsumeIAPItemQuery {
+	return metaapiwrapper.OculusConsumeIAPItemQuery{
+		VerifyItemOwnershipQuery: metaapiwrapper.VerifyItemOwnershipQuery{UsrID: "u1", SKU: "gem"},
+		IdempotencyKey:           key,
+	}
+}
+
+func TestConsumeWithLedgerIdempotent(t *testing.T) {
+	s := metaapitest.NewServer("app", "secret")
+	defer s.Close()
+	s.AddEntitlement("u1", metaapitest.Entitlement{SKU: "gem", Consumable: true})
+
+	repo := metaapiwrapper.NewMetaApiRepository(append(s.Options(), metaapiwrapper.WithConsumptionLedger(metaapiwrapper.NewMemoryConsumptionLedger()))...)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if resp, err := repo.RequestOculusConsumeIAPItemCtx(ctx, consumeQuery("k1")); err != nil || !resp.Success {
+			t.Fatalf("consume %v: %+v, %v", i, resp, err)
+		}
+	}
+	if n := s.Calls(metaapitest.EndpointConsumeEntitlement); n != 1 {
+		t.Errorf("%v consume calls, want 1", n)
+	}
+
+	q := consumeQuery("k1")
+	q.SKU = "other"
+	if _, err := repo.RequestOculusConsumeIAPItemCtx(ctx, q); !errors.Is(err, metaapiwrapper.ErrIdempotencyKeyReused) {
+		t.Errorf("got %v, want ErrIdempotencyKeyReused", err)
+	}
+}
+
+func TestConsumeWithLedgerPending(t *testing.T) {
+	s := metaapitest.NewServer("app", "secret")
+	defer s.Close()
+	s.AddEntitlement("u1", metaapitest.Entitlement{SKU: "gem", Consumable: true})
+
+	ledger := metaapiwrapper.NewMemoryConsumptionLedger()
+	repo := metaapiwrapper.NewMetaApiRepository(append(s.Options(),
+		metaapiwrapper.WithConsumptionLedger(ledger),
+		metaapiwrapper.WithConsumptionPendingTTL(time.Minute))...)
+	ctx := context.Background()
+
+	reserve := func(key string, at time.Time) {
+		_, _, err := ledger.Reserve(ctx, metaapiwrapper.ConsumptionRecord{IdempotencyKey: key, UserID: "u1", SKU: "gem", State: metaapiwrapper.ConsumptionPending, RecordedAt: at})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// held by a live request
+	reserve("fresh", time.Now())
+	if _, err := repo.RequestOculusConsumeIAPItemCtx(ctx, consumeQuery("fresh")); !errors.Is(err, metaapiwrapper.ErrConsumptionInProgress) {
+		t.Fatalf("got %v, want ErrConsumptionInProgress", err)
+	}
+
+	// left behind by a crashed process
+	reserve("stale", time.Now().Add(-time.Hour))
+	if resp, err := repo.RequestOculusConsumeIAPItemCtx(ctx, consumeQuery("stale")); err != nil || !resp.Success {
+		t.Fatalf("got %+v, %v", resp, err)
+	}
+	if resp, err := repo.RequestOculusConsumeIAPItemCtx(ctx, consumeQuery("stale")); err != nil || !resp.Success {
+		t.Fatalf("replay got %+v, %v", resp, err)
+	}
+	if n := s.Calls(metaapitest.EndpointConsumeEntitlement); n != 1 {
+		t.Errorf("%v consume calls, want 1", n)
+	}
+}
+
+// failingCompleteLedger loses every Complete, as a database outage right after the consume would
+type failingCompleteLedger struct {
+	*metaapiwrapper.MemoryConsumptionLedger
+}
+
+func (failingCompleteLedger) Complete(context.Context, string) error {
+	return errors.New("database down")
+}
+
+func TestConsumeWithLedgerCompleteFails(t *testing.T) {
+	s := metaapitest.NewServer("app", "secret")
+	defer s.Close()
+	s.AddEntitlement("u1", metaapitest.Entitlement{SKU: "gem", Consumable: true})
+
+	ledger := failingCompleteLedger{metaapiwrapper.NewMemoryConsumptionLedger()}
+	repo := metaapiwrapper.NewMetaApiRepository(append(s.Options(), metaapiwrapper.WithConsumptionLedger(ledger))...)
+
+	resp, err := repo.RequestOculusConsumeIAPItemCtx(context.Background(), consumeQuery("k1"))
+	if !resp.Success || !errors.Is(err, metaapiwrapper.ErrConsumptionUnrecorded) {
+		t.Fatalf("got %+v, %v; want success with ErrConsumptionUnrecorded", resp, err)
+	}
+	if len(s.Entitlements("u1")) != 0 {
+		t.Error("item was not consumed")
+	}
+}
+
+func TestSQLConsumptionLedgerTableName(t *testing.T) {
+	for _, table := range []string{"ledger", "app.consumption_ledger"} {
+		if _, err := metaapiwrapper.NewSQLConsumptionLedger(nil, table); err != nil {
+			t.Errorf("%q: %v", table, err)
+		}
+	}
+	for _, table := range []string{"", "ledger; DROP TABLE users", "`ledger`", "a.b.c"} {
+		if _, err := metaapiwrapper.NewSQLConsumptionLedger(nil, table); !errors.Is(err, metaapiwrapper.ErrInvalidTableName) {
+			t.Errorf("%q: got %v, want ErrInvalidTableName", table, err)
+		}
+	}
+}
+
+// lostConsumeTransport lets consume_entitlement reach Graph but never delivers the answer, as a timeout after Graph applied it would
+type lostConsumeTransport struct {
+	next http.RoundTripper
+}
+
+func (t lostConsumeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || !strings.HasSuffix(req.URL.Path, "/consume_entitlement") {
+		return resp, err
+	}
+	resp.Body.Close()
+
+	<-req.Context().Done()
+	return nil, req.Context().Err()
+}
+
+func TestConsumeWithLedgerLostResponse(t *testing.T) {
+	s := metaapitest.NewServer("app", "secret")
+	defer s.Close()
+	s.AddEntitlement("u1", metaapitest.Entitlement{SKU: "gem", Consumable: true})
+
+	ledger := metaapiwrapper.NewMemoryConsumptionLedger()
+	consume := func(ttl time.Duration) error {
+		repo := metaapiwrapper.NewMetaApiRepository(append(s.Options(),
+			metaapiwrapper.WithRoundTripper(lostConsumeTransport{next: s.Client().Transport}),
+			metaapiwrapper.WithConsumptionLedger(ledger),
+			metaapiwrapper.WithConsumptionPendingTTL(ttl))...)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err := repo.RequestOculusConsumeIAPItemCtx(ctx, consumeQuery("k1"))
+		return err
+	}
+
+	if err := consume(time.Hour); !errors.Is(err, metaapiwrapper.ErrConsumptionOutcomeUnknown) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want ErrConsumptionOutcomeUnknown wrapping the timeout", err)
+	}
+	if len(s.Entitlements("u1")) != 0 {
+		t.Fatal("the fake server did not apply the consume")
+	}
+
+	// the client retries with the same key
+	if err := consume(time.Hour); !errors.Is(err, metaapiwrapper.ErrConsumptionInProgress) {
+		t.Errorf("retry: got %v, want ErrConsumptionInProgress", err)
+	}
+
+	// once stale, the reservation is checked against verify_entitlement instead of consumed blindly
+	time.Sleep(5 * time.Millisecond)
+	if err := consume(time.Millisecond); !errors.Is(err, metaapiwrapper.ErrConsumptionOutcomeUnknown) || !errors.Is(err, metaapiwrapper.ErrEntitlementMissing) {
+		t.Errorf("stale retry: got %v, want ErrConsumptionOutcomeUnknown", err)
+	}
+
+	if n := s.Calls(metaapitest.EndpointConsumeEntitlement); n != 1 {
+		t.Errorf("%v consume calls, want 1", n)
+	}
+}
+
+func TestConsumeWithLedgerRejectedReleases(t *testing.T) {
+	s := metaapitest.NewServer("app", "secret")
+	defer s.Close()
+
+	repo := metaapiwrapper.NewMetaApiRepository(append(s.Options(), metaapiwrapper.WithConsumptionLedger(metaapiwrapper.NewMemoryConsumptionLedger()))...)
+	ctx := context.Background()
+
+	if _, err := repo.RequestOculusConsumeIAPItemCtx(ctx, consumeQuery("k1")); !errors.Is(err, metaapiwrapper.ErrEntitlementMissing) || errors.Is(err, metaapiwrapper.ErrConsumptionOutcomeUnknown) {
+		t.Fatalf("got %v, want a plain ErrEntitlementMissing", err)
+	}
+
+	// a rejection frees the key for a retry once the purchase lands
+	s.AddEntitlement("u1", metaapitest.Entitlement{SKU: "gem", Consumable: true})
+	if resp, err := repo.RequestOculusConsumeIAPItemCtx(ctx, consumeQuery("k1")); err != nil || !resp.Success {
+		t.Fatalf("retry: got %+v, %v", resp, err)
+	}
+}
+
+func TestConsumeWithLedgerTransientKeepsKey(t *testing.T) {
+	s := metaapitest.NewServer("app", "secret")
+	defer s.Close()
+	s.AddEntitlement("u1", metaapitest.Entitlement{SKU: "gem", Consumable: true})
+	s.InjectError(metaapitest.EndpointConsumeEntitlement, metaapiwrapper.MetaAPIError{StatusCode: http.StatusInternalServerError, Code: 2, Message: "down"}, 1)
+
+	repo := metaapiwrapper.NewMetaApiRepository(append(s.Options(), metaapiwrapper.WithConsumptionLedger(metaapiwrapper.NewMemoryConsumptionLedger()))...)
+	ctx := context.Background()
+
+	if _, err := repo.RequestOculusConsumeIAPItemCtx(ctx, consumeQuery("k1")); !errors.Is(err, metaapiwrapper.ErrConsumptionOutcomeUnknown) {
+		t.Fatalf("got %v, want ErrConsumptionOutcomeUnknown", err)
+	}
+	if _, err := repo.RequestOculusConsumeIAPItemCtx(ctx, consumeQuery("k1")); !errors.Is(err, metaapiwrapper.ErrConsumptionInProgress) {
+		t.Errorf("retry: got %v, want ErrConsumptionInProgress", err)
+	}
+}
@@ -0,0 +1,270 @@
+package metaapiwrapper
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultMaxLoginBodyBytes = 4 << 10
+	defaultSessionTTL        = 24 * time.Hour
+)
+
+// login error codes, sent in LoginError.Code
+const (
+	LoginErrorInvalidRequest   = "invalid_request"
+	LoginErrorInvalidNonce     = "invalid_nonce"
+	LoginErrorUnknownUser      = "unknown_user"
+	LoginErrorGraphUnavailable = "graph_unavailable"
+	LoginErrorGraphError       = "graph_error"
+	LoginErrorSession          = "session_error"
+)
+
+// LoginIdentity is the user verified by LoginHandler
+type LoginIdentity struct {
+	AppScopedID AppScopedID `json:"app_scoped_id"`
+	OrgScopedID OrgScopedID `json:"org_scoped_id"`
+}
+
+// Session is whatever the SessionIssuer hands back to the client
+type Session struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// SessionIssuer mints a session for a verified identity
+type SessionIssuer interface {
+	IssueSession(ctx context.Context, identity LoginIdentity) (Session, error)
+}
+
+// SessionIssuerFunc adapts a function to SessionIssuer
+type SessionIssuerFunc func(ctx context.Context, identity LoginIdentity) (Session, error)
+
+func (f SessionIssuerFunc) IssueSession(ctx context.Context, identity LoginIdentity) (Session, error) {
+	return f(ctx, identity)
+}
+
+// LoginRequest is the JSON body posted by the headset, as returned by Users.GetUserProof
+type LoginRequest struct {
+	UserID string `json:"user_id"`
+	Nonce  string `json:"nonce"`
+}
+
+// LoginResponse is the JSON body of a successful login
+type LoginResponse struct {
+	LoginIdentity
+	Session
+}
+
+// LoginError is the JSON body of a failed login
+type LoginError struct {
+	Code    string `json:"error"`
+	Message string `json:"message"`
+}
+
+// LoginHandlerConfig tunes LoginHandler; only Issuer is required
+type LoginHandlerConfig struct {
+	Issuer SessionIssuer
+	// Resolver of org scoped IDs, one backed by an in-memory store when nil
+	Resolver *ScopedIDResolver
+	// MaxBodyBytes of the request, 4KiB when 0
+	MaxBodyBytes int64
+	// Logger receives failed logins, discarded when nil
+	Logger *slog.Logger
+}
+
+// LoginHandler performs the Quest login flow: nonce validation, org scoped ID resolution, then session issuing.
+// It answers 400 for malformed requests, 401 for invalid nonces or unknown users,
+// 503 when Graph is unavailable or rate limited and 502 for other Graph failures
+type LoginHandler struct {
+	repo         MetaApiRepository
+	issuer       SessionIssuer
+	resolver     *ScopedIDResolver
+	maxBodyBytes int64
+	logger       *slog.Logger
+}
+
+func NewLoginHandler(repo MetaApiRepository, cfg LoginHandlerConfig) *LoginHandler {
+	h := &LoginHandler{
+		repo:         repo,
+		issuer:       cfg.Issuer,
+		resolver:     cfg.Resolver,
+		maxBodyBytes: cfg.MaxBodyBytes,
+		logger:       cfg.Logger,
+	}
+
+	if h.resolver == nil {
+		h.resolver = NewScopedIDResolver(repo, nil)
+	}
+	if h.maxBodyBytes <= 0 {
+		h.maxBodyBytes = defaultMaxLoginBodyBytes
+	}
+	if h.logger == nil {
+		h.logger = discardLogger
+	}
+	return h
+}
+
+func (h *LoginHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeLoginError(w, http.StatusMethodNotAllowed, LoginErrorInvalidRequest, "use POST")
+		return
+	}
+
+	var in LoginRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, h.maxBodyBytes)).Decode(&in); err != nil {
+		writeLoginError(w, http.StatusBadRequest, LoginErrorInvalidRequest, "malformed JSON body")
+		return
+	}
+	if len(in.UserID) <= 0 || len(in.Nonce) <= 0 {
+		writeLoginError(w, http.StatusBadRequest, LoginErrorInvalidRequest, "user_id and nonce are required")
+		return
+	}
+
+	resp, err := h.Login(r.Context(), in)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	writeLoginJSON(w, http.StatusOK, resp)
+}
+
+// Login runs the flow without HTTP, for callers with their own transport
+func (h *LoginHandler) Login(ctx context.Context, in LoginRequest) (resp LoginResponse, err error) {
+	if _, err = h.repo.RequestOculusUserNonceValidateCtx(ctx, UserNonceValidateQuery{UserID: in.UserID, Nonce: in.Nonce}); err != nil {
+		return
+	}
+
+	resp.AppScopedID = AppScopedID(in.UserID)
+	if resp.OrgScopedID, err = h.resolver.Resolve(ctx, resp.AppScopedID); err != nil {
+		// a mapping that could not be saved is still a valid login
+		if len(resp.OrgScopedID) <= 0 {
+			return
+		}
+		h.logger.WarnContext(ctx, "metaapiwrapper: login", "app_scoped_id", in.UserID, "error", err)
+	}
+
+	if resp.Session, err = h.issuer.IssueSession(ctx, resp.LoginIdentity); err != nil {
+		err = &sessionError{err: err}
+	}
+	return
+}
+
+// sessionError marks failures of the SessionIssuer
+type sessionError struct {
+	err error
+}
+
+func (e *sessionError) Error() string {
+	return fmt.Sprintf("metaapiwrapper: issue session: %v", e.err)
+}
+
+func (e *sessionError) Unwrap() error {
+	return e.err
+}
+
+func (h *LoginHandler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	h.logger.WarnContext(r.Context(), "metaapiwrapper: login failed", "error", err)
+
+	var apiErr *MetaAPIError
+	var sessErr *sessionError
+	switch {
+	case errors.Is(err, ErrNonceInvalid):
+		writeLoginError(w, http.StatusUnauthorized, LoginErrorInvalidNonce, "nonce is invalid or already used")
+	case errors.Is(err, ErrOrgScopedIDNotFound):
+		writeLoginError(w, http.StatusUnauthorized, LoginErrorUnknownUser, "user is unknown to this app")
+	case errors.As(err, &sessErr):
+		writeLoginError(w, http.StatusInternalServerError, LoginErrorSession, "cannot issue session")
+	case errors.As(err, &apiErr) && !IsTransient(err):
+		writeLoginError(w, http.StatusBadGateway, LoginErrorGraphError, "Oculus platform rejected the login")
+	default:
+		// rate limits, 5xx, timeouts and unreachable Graph
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(apiErr.RetryAfter.Seconds()))))
+		}
+		writeLoginError(w, http.StatusServiceUnavailable, LoginErrorGraphUnavailable, "Oculus platform is unavailable, retry later")
+	}
+}
+
+func writeLoginError(w http.ResponseWriter, status int, code, message string) {
+	writeLoginJSON(w, status, LoginError{Code: code, Message: message})
+}
+
+func writeLoginJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// HS256SessionIssuer issues JWTs signed with HMAC-SHA256; sub is the org scoped ID
+type HS256SessionIssuer struct {
+	secret   []byte
+	issuer   string
+	audience string
+	ttl      time.Duration
+}
+
+// NewHS256SessionIssuer signs with secret; iss and aud are omitted when empty and ttl is 24 hours when 0
+func NewHS256SessionIssuer(secret []byte, issuer, audience string, ttl time.Duration) *HS256SessionIssuer {
+	if ttl <= 0 {
+		ttl = defaultSessionTTL
+	}
+
+	return &HS256SessionIssuer{
+		secret:   secret,
+		issuer:   issuer,
+		audience: audience,
+		ttl:      ttl,
+	}
+}
+
+type sessionClaims struct {
+	Subject     string `json:"sub"`
+	AppScopedID string `json:"app_scoped_id"`
+	Issuer      string `json:"iss,omitempty"`
+	Audience    string `json:"aud,omitempty"`
+	IssuedAt    int64  `json:"iat"`
+	ExpiresAt   int64  `json:"exp"`
+}
+
+func (s *HS256SessionIssuer) IssueSession(_ context.Context, identity LoginIdentity) (session Session, err error) {
+	if len(s.secret) <= 0 {
+		err = errors.New("metaapiwrapper: empty session secret")
+		return
+	}
+
+	now := time.Now()
+	session.ExpiresAt = now.Add(s.ttl).Truncate(time.Second)
+
+	var claims []byte
+	if claims, err = json.Marshal(sessionClaims{
+		Subject:     string(identity.OrgScopedID),
+		AppScopedID: string(identity.AppScopedID),
+		Issuer:      s.issuer,
+		Audience:    s.audience,
+		IssuedAt:    now.Unix(),
+		ExpiresAt:   session.ExpiresAt.Unix(),
+	}); err != nil {
+		return
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte(unsigned))
+	session.Token = unsigned + "." + base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+	return
+}
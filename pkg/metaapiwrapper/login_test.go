@@ -0,0 +1,180 @@
+package metaapiwrapper_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperbting/api-library/pkg/metaapiwrapper"
+	"github.com/hyperbting/api-library/pkg/metaapiwrapper/metaapitest"
+)
+
+var testSessionSecret = []byte("session secret")
+
+func TestLoginHandler(t *testing.T) {
+	failingIssuer := metaapiwrapper.SessionIssuerFunc(func(context.Context, metaapiwrapper.LoginIdentity) (metaapiwrapper.Session, error) {
+		return metaapiwrapper.Session{}, errors.New("keys unavailable")
+	})
+
+	tests := []struct {
+		name     string
+		method   string
+		body     string
+		setup    func(s *metaapitest.Server)
+		issuer   metaapiwrapper.SessionIssuer
+		status   int
+		errCode  string
+		graphOps int
+	}{
+		{name: "ok", body: `{"user_id":"u1","nonce":"n1"}`, status: http.StatusOK, graphOps: 1},
+		{name: "wrong nonce", body: `{"user_id":"u1","nonce":"other"}`, status: http.StatusUnauthorized, errCode: metaapiwrapper.LoginErrorInvalidNonce, graphOps: 1},
+		{name: "nonce of another user", body: `{"user_id":"u2","nonce":"n1"}`, status: http.StatusUnauthorized, errCode: metaapiwrapper.LoginErrorInvalidNonce, graphOps: 1},
+		{name: "unknown user", body: `{"user_id":"u3","nonce":"n3"}`, status: http.StatusUnauthorized, errCode: metaapiwrapper.LoginErrorUnknownUser, graphOps: 1},
+		{name: "malformed body", body: `{"user_id":`, status: http.StatusBadRequest, errCode: metaapiwrapper.LoginErrorInvalidRequest},
+		{name: "missing nonce", body: `{"user_id":"u1"}`, status: http.StatusBadRequest, errCode: metaapiwrapper.LoginErrorInvalidRequest},
+		{name: "body too large", body: `{"user_id":"u1","nonce":"` + strings.Repeat("n", 8<<10) + `"}`, status: http.StatusBadRequest, errCode: metaapiwrapper.LoginErrorInvalidRequest},
+		{name: "GET", method: http.MethodGet, status: http.StatusMethodNotAllowed, errCode: metaapiwrapper.LoginErrorInvalidRequest},
+		{
+			name: "graph down",
+			body: `{"user_id":"u1","nonce":"n1"}`,
+			setup: func(s *metaapitest.Server) {
+				s.InjectError(metaapitest.EndpointUserNonceValidate, metaapiwrapper.MetaAPIError{StatusCode: http.StatusServiceUnavailable, Code: 2, Message: "down"}, 0)
+			},
+			status:   http.StatusServiceUnavailable,
+			errCode:  metaapiwrapper.LoginErrorGraphUnavailable,
+			graphOps: 1,
+		},
+		{
+			name: "invalid app token",
+			body: `{"user_id":"u1","nonce":"n1"}`,
+			setup: func(s *metaapitest.Server) {
+				s.InjectError(metaapitest.EndpointUserNonceValidate, metaapiwrapper.MetaAPIError{StatusCode: http.StatusBadRequest, Code: 190, Message: "bad token"}, 0)
+			},
+			status:   http.StatusBadGateway,
+			errCode:  metaapiwrapper.LoginErrorGraphError,
+			graphOps: 1,
+		},
+		{name: "session issuer fails", body: `{"user_id":"u1","nonce":"n1"}`, issuer: failingIssuer, status: http.StatusInternalServerError, errCode: metaapiwrapper.LoginErrorSession, graphOps: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := metaapitest.NewServer("app", "secret")
+			defer s.Close()
+			s.AddUser(metaapitest.User{ID: "u1", OrgScopedID: "org1"})
+			s.AddUser(metaapitest.User{ID: "u2", OrgScopedID: "org2"})
+			s.AddNonce("u1", "n1")
+			s.AddNonce("u3", "n3")
+			if tt.setup != nil {
+				tt.setup(s)
+			}
+
+			issuer := tt.issuer
+			if issuer == nil {
+				issuer = metaapiwrapper.NewHS256SessionIssuer(testSessionSecret, "game", "players", time.Hour)
+			}
+			h := metaapiwrapper.NewLoginHandler(metaapiwrapper.NewMetaApiRepository(s.Options()...), metaapiwrapper.LoginHandlerConfig{Issuer: issuer})
+
+			method := tt.method
+			if len(method) <= 0 {
+				method = http.MethodPost
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(method, "/login", strings.NewReader(tt.body)))
+
+			if rec.Code != tt.status {
+				t.Fatalf("status %v, want %v: %v", rec.Code, tt.status, rec.Body)
+			}
+			if n := s.Calls(metaapitest.EndpointUserNonceValidate); n != tt.graphOps {
+				t.Errorf("%v nonce validations, want %v", n, tt.graphOps)
+			}
+
+			if tt.status != http.StatusOK {
+				var out metaapiwrapper.LoginError
+				if err := json.NewDecoder(rec.Body).Decode(&out); err != nil || out.Code != tt.errCode {
+					t.Errorf("got %+v, %v; want error %v", out, err, tt.errCode)
+				}
+				return
+			}
+
+			var out metaapiwrapper.LoginResponse
+			if err := json.NewDecoder(rec.Body).Decode(&out); err != nil {
+				t.Fatal(err)
+			}
+			if out.AppScopedID != "u1" || out.OrgScopedID != "org1" {
+				t.Errorf("identity %+v", out.LoginIdentity)
+			}
+			checkSessionToken(t, out.Session, "org1")
+		})
+	}
+}
+
+// checkSessionToken verifies the HS256 signature and the claims of session
+func checkSessionToken(t *testing.T, session metaapiwrapper.Session, subject string) {
+	t.Helper()
+
+	parts := strings.Split(session.Token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("token %q is not a JWT", session.Token)
+	}
+
+	mac := hmac.New(sha256.New, testSessionSecret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if got, err := base64.RawURLEncoding.DecodeString(parts[2]); err != nil || !hmac.Equal(got, mac.Sum(nil)) {
+		t.Errorf("bad signature: %v", err)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Typ string `json:"typ"`
+	}
+	var claims struct {
+		Sub string `json:"sub"`
+		Iss string `json:"iss"`
+		Aud string `json:"aud"`
+		Exp int64  `json:"exp"`
+	}
+	for i, v := range []interface{}{&header, &claims} {
+		raw, err := base64.RawURLEncoding.DecodeString(parts[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = json.Unmarshal(raw, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if header.Alg != "HS256" || header.Typ != "JWT" {
+		t.Errorf("header %+v", header)
+	}
+	if claims.Sub != subject || claims.Iss != "game" || claims.Aud != "players" || claims.Exp != session.ExpiresAt.Unix() {
+		t.Errorf("claims %+v, expires at %v", claims, session.ExpiresAt)
+	}
+}
+
+func TestLoginHandlerNonceSingleUse(t *testing.T) {
+	s := metaapitest.NewServer("app", "secret")
+	defer s.Close()
+	s.AddUser(metaapitest.User{ID: "u1", OrgScopedID: "org1"})
+	s.AddNonce("u1", "n1")
+
+	h := metaapiwrapper.NewLoginHandler(metaapiwrapper.NewMetaApiRepository(s.Options()...), metaapiwrapper.LoginHandlerConfig{
+		Issuer: metaapiwrapper.NewHS256SessionIssuer(testSessionSecret, "", "", 0),
+	})
+
+	for _, want := range []int{http.StatusOK, http.StatusUnauthorized} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"user_id":"u1","nonce":"n1"}`)))
+		if rec.Code != want {
+			t.Errorf("status %v, want %v: %v", rec.Code, want, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Package metaapiotel reports metaapiwrapper Graph calls to OpenTelemetry
+package metaapiotel
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/hyperbting/api-library/pkg/metaapiwrapper"
+)
+
+const instrumentationName = "github.com/hyperbting/api-library/pkg/metaapiwrapper"
+
+// Instrumentation emits one client span and request count/latency metrics per Graph call
+type Instrumentation struct {
+	tracer   trace.Tracer
+	requests metric.Int64Counter
+	latency  metric.Float64Histogram
+}
+
+// New builds an Instrumentation for metaapiwrapper.WithInstrumentation, e.g. New(otel.GetTracerProvider(), otel.GetMeterProvider())
+func New(tp trace.TracerProvider, mp metric.MeterProvider) (i *Instrumentation, err error) {
+	meter := mp.Meter(instrumentationName)
+
+	i = &Instrumentation{
+		tracer: tp.Tracer(instrumentationName),
+	}
+
+	if i.requests, err = meter.Int64Counter("metaapiwrapper.requests",
+		metric.WithDescription("Oculus Graph calls"),
+		metric.WithUnit("{request}")); err != nil {
+		return nil, err
+	}
+
+	if i.latency, err = meter.Float64Histogram("metaapiwrapper.request.duration",
+		metric.WithDescription("Oculus Graph call latency, retries included"),
+		metric.WithUnit("s")); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (i *Instrumentation) StartCall(ctx context.Context, info metaapiwrapper.CallInfo) (context.Context, func(metaapiwrapper.CallResult)) {
+	base := []attribute.KeyValue{
+		attribute.String("oculus.endpoint", info.Endpoint),
+		attribute.String("oculus.app_id", info.AppID),
+	}
+
+	ctx, span := i.tracer.Start(ctx, "oculus "+info.Endpoint,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(base...))
+
+	return ctx, func(result metaapiwrapper.CallResult) {
+		defer span.End()
+
+		attrs := append(base, attribute.Int("http.response.status_code", result.StatusCode))
+		if result.GraphCode != 0 {
+			attrs = append(attrs, attribute.Int("oculus.graph.error_code", result.GraphCode))
+		}
+
+		span.SetAttributes(attribute.Int("http.response.status_code", result.StatusCode), attribute.Int("oculus.attempts", result.Attempts))
+		if result.GraphCode != 0 {
+			span.SetAttributes(
+				attribute.Int("oculus.graph.error_code", result.GraphCode),
+				attribute.Int("oculus.graph.error_subcode", result.GraphSubcode),
+				attribute.String("oculus.graph.fbtrace_id", result.FBTraceID))
+		}
+		if result.Err != nil {
+			span.RecordError(result.Err)
+			span.SetStatus(codes.Error, result.Err.Error())
+		}
+
+		attrs = append(attrs, attribute.Bool("error", result.Err != nil))
+		i.requests.Add(ctx, 1, metric.WithAttributes(attrs...))
+		i.latency.Record(ctx, result.Duration.Seconds(), metric.WithAttributes(attrs...))
+	}
+}
@@ -0,0 +1,172 @@
+package metaapiotel_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/hyperbting/api-library/pkg/metaapiwrapper"
+	"github.com/hyperbting/api-library/pkg/metaapiwrapper/metaapiotel"
+	"github.com/hyperbting/api-library/pkg/metaapiwrapper/metaapitest"
+)
+
+func TestInstrumentation(t *testing.T) {
+	s := metaapitest.NewServer("app", "secret")
+	defer s.Close()
+	s.AddEntitlement("u1", metaapitest.Entitlement{SKU: "sku"})
+
+	spans := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(spans))
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	instrumentation, err := metaapiotel.New(tp, mp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := metaapiwrapper.NewMetaApiRepository(append(s.Options(),
+		metaapiwrapper.WithCircuitBreaker(metaapiwrapper.BreakerConfig{FailureThreshold: 1, CoolDown: time.Hour}),
+		metaapiwrapper.WithInstrumentation(instrumentation))...)
+	ctx := context.Background()
+	verify := func() error {
+		_, err := repo.RequestOculusVerifyItemOwnershipCtx(ctx, metaapiwrapper.VerifyItemOwnershipQuery{UsrID: "u1", SKU: "sku"})
+		return err
+	}
+
+	if err := verify(); err != nil {
+		t.Fatal(err)
+	}
+	s.InjectError(metaapitest.EndpointVerifyEntitlement, metaapiwrapper.MetaAPIError{StatusCode: http.StatusServiceUnavailable, Code: 2, ErrorSubcode: 7, FBTraceID: "trace-1", Message: "down"}, 0)
+	if err := verify(); !metaapiwrapper.IsTransient(err) {
+		t.Fatalf("got %v, want the transient error", err)
+	}
+	// the circuit is open: no request, but still exactly one span and one measurement
+	if err := verify(); !errors.Is(err, metaapiwrapper.ErrCircuitOpen) {
+		t.Fatalf("got %v, want ErrCircuitOpen", err)
+	}
+
+	ended := spans.GetSpans()
+	if len(ended) != 3 {
+		t.Fatalf("%v spans, want 3", len(ended))
+	}
+	tests := []struct {
+		name   string
+		status codes.Code
+		want   map[attribute.Key]attribute.Value
+	}{
+		{
+			name:   "success",
+			status: codes.Unset,
+			want: map[attribute.Key]attribute.Value{
+				"http.response.status_code": attribute.IntValue(http.StatusOK),
+				"oculus.attempts":           attribute.IntValue(1),
+			},
+		},
+		{
+			name:   "graph error",
+			status: codes.Error,
+			want: map[attribute.Key]attribute.Value{
+				"http.response.status_code":  attribute.IntValue(http.StatusServiceUnavailable),
+				"oculus.attempts":            attribute.IntValue(1),
+				"oculus.graph.error_code":    attribute.IntValue(2),
+				"oculus.graph.error_subcode": attribute.IntValue(7),
+				"oculus.graph.fbtrace_id":    attribute.StringValue("trace-1"),
+			},
+		},
+		{
+			name:   "short circuited",
+			status: codes.Error,
+			want: map[attribute.Key]attribute.Value{
+				"http.response.status_code": attribute.IntValue(0),
+				"oculus.attempts":           attribute.IntValue(0),
+			},
+		},
+	}
+	for i, tt := range tests {
+		span := ended[i]
+		if span.Name != "oculus verify_entitlement" || span.SpanKind != trace.SpanKindClient {
+			t.Errorf("%v: span %q of kind %v", tt.name, span.Name, span.SpanKind)
+		}
+		if span.Status.Code != tt.status {
+			t.Errorf("%v: status %v, want %v", tt.name, span.Status.Code, tt.status)
+		}
+
+		got := map[attribute.Key]attribute.Value{}
+		for _, kv := range span.Attributes {
+			got[kv.Key] = kv.Value
+		}
+		tt.want["oculus.endpoint"] = attribute.StringValue(metaapiwrapper.EndpointVerifyEntitlement)
+		tt.want["oculus.app_id"] = attribute.StringValue("app")
+		for key, value := range tt.want {
+			if got[key] != value {
+				t.Errorf("%v: %v = %v, want %v", tt.name, key, got[key].Emit(), value.Emit())
+			}
+		}
+		if _, ok := got["oculus.graph.error_code"]; ok && tt.want["oculus.graph.error_code"].Type() == attribute.INVALID {
+			t.Errorf("%v: Graph error code without a Graph error", tt.name)
+		}
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	var requests metricdata.Sum[int64]
+	var latency metricdata.Histogram[float64]
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch m.Name {
+			case "metaapiwrapper.requests":
+				requests = m.Data.(metricdata.Sum[int64])
+			case "metaapiwrapper.request.duration":
+				latency = m.Data.(metricdata.Histogram[float64])
+			}
+		}
+	}
+
+	// one series per outcome: success, the Graph error and the short circuited call
+	counts := map[string]int64{}
+	for _, dp := range requests.DataPoints {
+		status, _ := dp.Attributes.Value("http.response.status_code")
+		failed, _ := dp.Attributes.Value("error")
+		key := status.Emit() + "/" + failed.Emit()
+		if code, ok := dp.Attributes.Value("oculus.graph.error_code"); ok {
+			key += "/" + code.Emit()
+		}
+		counts[key] += dp.Value
+
+		if endpoint, _ := dp.Attributes.Value("oculus.endpoint"); endpoint.AsString() != metaapiwrapper.EndpointVerifyEntitlement {
+			t.Errorf("endpoint %v", endpoint.Emit())
+		}
+		if appID, _ := dp.Attributes.Value("oculus.app_id"); appID.AsString() != "app" {
+			t.Errorf("app ID %v", appID.Emit())
+		}
+	}
+	want := map[string]int64{"200/false": 1, "503/true/2": 1, "0/true": 1}
+	if len(counts) != len(want) {
+		t.Errorf("request counts %v, want %v", counts, want)
+	}
+	for key, n := range want {
+		if counts[key] != n {
+			t.Errorf("request count %v = %v, want %v", key, counts[key], n)
+		}
+	}
+
+	var measured uint64
+	for _, dp := range latency.DataPoints {
+		measured += dp.Count
+	}
+	if measured != 3 {
+		t.Errorf("%v latency measurements, want 3", measured)
+	}
+}
@@ -0,0 +1,200 @@
+package metaapitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+
+	"github.com/hyperbting/api-library/pkg/metaapiwrapper"
+)
+
+// ErrNoInteraction is returned by a replaying Recorder for requests missing from the golden file
+var ErrNoInteraction = errors.New("metaapitest: no recorded interaction")
+
+// Mode selects whether a Recorder talks to Graph or to its golden file
+type Mode int
+
+const (
+	// ModeReplay answers from the golden file only, never touching the network
+	ModeReplay Mode = iota
+	// ModeRecord forwards to the real transport and captures every exchange; call Save to write the golden file
+	ModeRecord
+)
+
+const scrubbed = "REDACTED"
+
+var (
+	// query parameters replaced before an exchange is recorded or matched
+	scrubbedParams = []string{"access_token", "nonce"}
+
+	// response headers kept in golden files; everything else may identify a session or a host
+	keptHeaders = []string{"Content-Type", "Retry-After", "Www-Authenticate"}
+)
+
+// Interaction is one recorded exchange, matched on Method, Path and the normalized Query
+type Interaction struct {
+	Method string      `json:"method"`
+	Path   string      `json:"path"`
+	Query  string      `json:"query"`
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body"`
+}
+
+// Cassette is the content of a golden file
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Recorder is an http.RoundTripper recording Graph exchanges into a golden file or replaying them from it.
+// Access tokens, app secrets and nonces are scrubbed from what is recorded.
+// Identical requests are replayed in recorded order, so a verify before and after a consume gets both answers
+type Recorder struct {
+	mode      Mode
+	path      string
+	transport http.RoundTripper
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+}
+
+// NewRecorder records through transport (http.DefaultTransport when nil) or replays path, depending on mode
+func NewRecorder(path string, mode Mode, transport http.RoundTripper) (r *Recorder, err error) {
+	r = &Recorder{
+		mode:      mode,
+		path:      path,
+		transport: transport,
+	}
+
+	if r.transport == nil {
+		r.transport = http.DefaultTransport
+	}
+
+	if mode == ModeReplay {
+		var data []byte
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(data, &r.cassette); err != nil {
+			return nil, fmt.Errorf("metaapitest: golden file %v: %w", path, err)
+		}
+		r.used = make([]bool, len(r.cassette.Interactions))
+	}
+	return
+}
+
+// Option routes a metaapiwrapper repository through r
+func (r *Recorder) Option() metaapiwrapper.Option {
+	return metaapiwrapper.WithRoundTripper(r)
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	if r.mode == ModeRecord {
+		return r.record(req)
+	}
+	return r.replay(req)
+}
+
+func (r *Recorder) record(req *http.Request) (resp *http.Response, err error) {
+	if resp, err = r.transport.RoundTrip(req); err != nil {
+		return
+	}
+
+	var body []byte
+	body, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	interaction := Interaction{
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Query:  normalizeQuery(req.URL),
+		Status: resp.StatusCode,
+		Header: http.Header{},
+		Body:   metaapiwrapper.RedactAccessToken(string(body)),
+	}
+	for _, key := range keptHeaders {
+		if values := resp.Header.Values(key); len(values) > 0 {
+			interaction.Header[key] = values
+		}
+	}
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	r.mu.Unlock()
+	return
+}
+
+func (r *Recorder) replay(req *http.Request) (*http.Response, error) {
+	query := normalizeQuery(req.URL)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, interaction := range r.cassette.Interactions {
+		if r.used[i] || interaction.Method != req.Method || interaction.Path != req.URL.Path || interaction.Query != query {
+			continue
+		}
+		r.used[i] = true
+
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", interaction.Status, http.StatusText(interaction.Status)),
+			StatusCode:    interaction.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        interaction.Header.Clone(),
+			Body:          io.NopCloser(bytes.NewReader([]byte(interaction.Body))),
+			ContentLength: int64(len(interaction.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("%w: %v %v?%v", ErrNoInteraction, req.Method, req.URL.Path, query)
+}
+
+// Unused lists the recorded interactions a replay never requested, to catch tests that stopped calling Graph
+func (r *Recorder) Unused() (unused []Interaction) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, interaction := range r.cassette.Interactions {
+		if i < len(r.used) && !r.used[i] {
+			unused = append(unused, interaction)
+		}
+	}
+	return
+}
+
+// Save writes the recorded exchanges to the golden file
+func (r *Recorder) Save() error {
+	r.mu.Lock()
+	data, err := json.MarshalIndent(r.cassette, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(r.path, append(data, '\n'), 0o644)
+}
+
+// normalizeQuery scrubs secrets and sorts parameters so that equivalent requests match
+func normalizeQuery(u *url.URL) string {
+	query := u.Query()
+	for _, key := range scrubbedParams {
+		if query.Has(key) {
+			query.Set(key, scrubbed)
+		}
+	}
+	return query.Encode()
+}
@@ -0,0 +1,409 @@
+// Package metaapitest provides an in-process fake of the Oculus Graph endpoints wrapped by metaapiwrapper
+package metaapitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hyperbting/api-library/pkg/metaapiwrapper"
+)
+
+// Endpoint names a fake Graph endpoint, used to inject errors and count calls
+type Endpoint string
+
+const (
+	EndpointUserNonceValidate  Endpoint = "user_nonce_validate"
+	EndpointVerifyEntitlement  Endpoint = "verify_entitlement"
+	EndpointConsumeEntitlement Endpoint = "consume_entitlement"
+	EndpointViewerPurchases    Endpoint = "viewer_purchases"
+	EndpointOrgScopedID        Endpoint = "org_scoped_id"
+)
+
+// DefaultPageSize of viewer_purchases when the request has no limit
+const DefaultPageSize = 25
+
+var apiVersionPattern = regexp.MustCompile(`^v[0-9]+\.[0-9]+$`)
+
+// User is an app scoped Oculus user and its org scoped ID
+type User struct {
+	ID          string
+	OrgScopedID string
+	Alias       string
+}
+
+// Entitlement is an item owned by a user; Consumable ones are removed by consume_entitlement
+type Entitlement struct {
+	ID             string
+	SKU            string
+	ItemID         string
+	GrantTime      int64
+	ExpirationTime int64
+	Consumable     bool
+}
+
+type failure struct {
+	err       metaapiwrapper.MetaAPIError
+	remaining int
+}
+
+// Server is a fake graph.oculus.com; point a repository at it with Options
+type Server struct {
+	*httptest.Server
+
+	AppID     string
+	AppSecret string
+	PageSize  int
+
+	mu           sync.Mutex
+	users        map[string]User
+	nonces       map[string]string
+	entitlements map[string][]Entitlement
+	failures     map[Endpoint]*failure
+	calls        map[Endpoint]int
+}
+
+// NewServer starts a fake Graph server for appID; call Close when done
+func NewServer(appID, appSecret string) *Server {
+	s := &Server{
+		AppID:        appID,
+		AppSecret:    appSecret,
+		PageSize:     DefaultPageSize,
+		users:        map[string]User{},
+		nonces:       map[string]string{},
+		entitlements: map[string][]Entitlement{},
+		failures:     map[Endpoint]*failure{},
+		calls:        map[Endpoint]int{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+
+	return s
+}
+
+// Options configures a metaapiwrapper repository to talk to s as its app
+func (s *Server) Options() []metaapiwrapper.Option {
+	return []metaapiwrapper.Option{
+		metaapiwrapper.WithBaseURL(s.URL),
+		metaapiwrapper.WithHTTPClient(s.Client()),
+		metaapiwrapper.WithPlatformConfig(s.PlatformConfig()),
+	}
+}
+
+// PlatformConfig of the fake app
+func (s *Server) PlatformConfig() metaapiwrapper.OCULUSPlatformConfig {
+	return metaapiwrapper.OCULUSPlatformConfig{
+		AppID:     s.AppID,
+		AppSecret: s.AppSecret,
+	}
+}
+
+// AccessToken is the app access token accepted by s
+func (s *Server) AccessToken() string {
+	cfg := s.PlatformConfig()
+	return cfg.FormAccessToken()
+}
+
+// AddUser seeds a user, replacing any user with the same ID
+func (s *Server) AddUser(u User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users[u.ID] = u
+}
+
+// AddNonce makes nonce valid for userID until it is validated once
+func (s *Server) AddNonce(userID, nonce string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nonces[nonce] = userID
+}
+
+// AddEntitlement grants e to userID; ID and ItemID are generated when empty
+func (s *Server) AddEntitlement(userID string, e Entitlement) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(e.ID) <= 0 {
+		e.ID = fmt.Sprintf("%v-%v-%v", userID, e.SKU, len(s.entitlements[userID]))
+	}
+	if len(e.ItemID) <= 0 {
+		e.ItemID = "item-" + e.SKU
+	}
+	s.entitlements[userID] = append(s.entitlements[userID], e)
+}
+
+// Entitlements currently owned by userID
+func (s *Server) Entitlements(userID string) []Entitlement {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Entitlement(nil), s.entitlements[userID]...)
+}
+
+// InjectError makes the next times calls of endpoint fail with err, forever when times <= 0.
+// err.StatusCode defaults to 400, a non-zero err.RetryAfter is sent as Retry-After
+func (s *Server) InjectError(endpoint Endpoint, err metaapiwrapper.MetaAPIError, times int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err.StatusCode == 0 {
+		err.StatusCode = http.StatusBadRequest
+	}
+	s.failures[endpoint] = &failure{err: err, remaining: times}
+}
+
+// ClearErrors drops every injected error
+func (s *Server) ClearErrors() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures = map[Endpoint]*failure{}
+}
+
+// Calls counts requests received by endpoint, injected failures included
+func (s *Server) Calls(endpoint Endpoint) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.calls[endpoint]
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeGraphError(w, graphError(http.StatusBadRequest, 100, 0, err.Error()))
+		return
+	}
+
+	endpoint, owner := route(r.URL.Path)
+	if len(endpoint) <= 0 {
+		writeGraphError(w, graphError(http.StatusNotFound, 100, 33, "Unknown path components: "+r.URL.Path))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls[endpoint]++
+	if f, ok := s.failures[endpoint]; ok {
+		if f.remaining > 0 {
+			if f.remaining--; f.remaining == 0 {
+				delete(s.failures, endpoint)
+			}
+		}
+		writeGraphError(w, f.err)
+		return
+	}
+
+	if r.Form.Get("access_token") != s.AccessToken() {
+		writeGraphError(w, graphError(http.StatusBadRequest, 190, 0, "Invalid OAuth access token."))
+		return
+	}
+
+	if endpoint != EndpointUserNonceValidate && endpoint != EndpointOrgScopedID && owner != s.AppID {
+		writeGraphError(w, graphError(http.StatusBadRequest, 100, 33, "Unsupported request for application "+owner))
+		return
+	}
+
+	switch endpoint {
+	case EndpointUserNonceValidate:
+		s.userNonceValidate(w, r)
+	case EndpointVerifyEntitlement:
+		s.verifyEntitlement(w, r)
+	case EndpointConsumeEntitlement:
+		s.consumeEntitlement(w, r)
+	case EndpointViewerPurchases:
+		s.viewerPurchases(w, r)
+	case EndpointOrgScopedID:
+		s.orgScopedID(w, owner)
+	}
+}
+
+// route maps a request path to its endpoint and the app or user ID in front of it; a Graph API version prefix is ignored
+func route(path string) (endpoint Endpoint, owner string) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) > 1 && apiVersionPattern.MatchString(segments[0]) {
+		segments = segments[1:]
+	}
+
+	switch {
+	case len(segments) == 1 && segments[0] == string(EndpointUserNonceValidate):
+		return EndpointUserNonceValidate, ""
+	case len(segments) == 1 && len(segments[0]) > 0:
+		return EndpointOrgScopedID, segments[0]
+	case len(segments) == 2:
+		switch Endpoint(segments[1]) {
+		case EndpointVerifyEntitlement, EndpointConsumeEntitlement, EndpointViewerPurchases:
+			return Endpoint(segments[1]), segments[0]
+		}
+	}
+	return "", ""
+}
+
+func (s *Server) userNonceValidate(w http.ResponseWriter, r *http.Request) {
+	userID, ok := s.nonces[r.Form.Get("nonce")]
+	valid := ok && userID == r.Form.Get("user_id")
+	if valid {
+		delete(s.nonces, r.Form.Get("nonce"))
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"is_valid": valid})
+}
+
+// userID resolves an app scoped or org scoped ID to the seeded app scoped ID
+func (s *Server) userID(id string) string {
+	if _, ok := s.users[id]; ok {
+		return id
+	}
+	for _, u := range s.users {
+		if len(u.OrgScopedID) > 0 && u.OrgScopedID == id {
+			return u.ID
+		}
+	}
+	return id
+}
+
+func (s *Server) verifyEntitlement(w http.ResponseWriter, r *http.Request) {
+	userID, sku := s.userID(r.Form.Get("user_id")), r.Form.Get("sku")
+
+	owned := false
+	for _, e := range s.entitlements[userID] {
+		if e.SKU == sku {
+			owned = true
+			break
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": owned})
+}
+
+func (s *Server) consumeEntitlement(w http.ResponseWriter, r *http.Request) {
+	userID, sku := s.userID(r.Form.Get("user_id")), r.Form.Get("sku")
+
+	consumed := false
+	owned := s.entitlements[userID]
+	for i, e := range owned {
+		if e.SKU == sku && e.Consumable {
+			s.entitlements[userID] = append(owned[:i:i], owned[i+1:]...)
+			consumed = true
+			break
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": consumed})
+}
+
+func (s *Server) viewerPurchases(w http.ResponseWriter, r *http.Request) {
+	owned := s.entitlements[s.userID(r.Form.Get("user_id"))]
+
+	limit := s.PageSize
+	if n, err := strconv.Atoi(r.Form.Get("limit")); err == nil && n > 0 {
+		limit = n
+	}
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+
+	start := 0
+	if after := r.Form.Get("after"); len(after) > 0 {
+		n, err := strconv.Atoi(after)
+		if err != nil || n < 0 || n > len(owned) {
+			writeGraphError(w, graphError(http.StatusBadRequest, 100, 0, "Invalid cursor"))
+			return
+		}
+		start = n
+	}
+	end := min(start+limit, len(owned))
+
+	data := make([]metaapiwrapper.OculusData, 0, end-start)
+	for _, e := range owned[start:end] {
+		data = append(data, metaapiwrapper.OculusData{
+			ID:             e.ID,
+			GrantTime:      e.GrantTime,
+			ExpirationTime: e.ExpirationTime,
+			Item: metaapiwrapper.OculusItem{
+				SKU: e.SKU,
+				ID:  e.ItemID,
+			},
+		})
+	}
+
+	paging := metaapiwrapper.OculusPaging{
+		Cursors: metaapiwrapper.OculusCursors{
+			Before: strconv.Itoa(start),
+			After:  strconv.Itoa(end),
+		},
+	}
+	if end < len(owned) {
+		paging.Next = s.pageURL(r, end)
+	}
+	if start > 0 {
+		paging.Previous = s.pageURL(r, max(start-limit, 0))
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"data":   data,
+		"paging": paging,
+	})
+}
+
+func (s *Server) pageURL(r *http.Request, after int) string {
+	query := url.Values{}
+	for key, values := range r.Form {
+		query[key] = values
+	}
+	query.Set("after", strconv.Itoa(after))
+
+	return s.URL + r.URL.Path + "?" + query.Encode()
+}
+
+// orgScopedID mirrors the swap documented on GetOculusOrgScopedID: id carries the org scoped ID, org_scoped_id the requested user ID
+func (s *Server) orgScopedID(w http.ResponseWriter, userID string) {
+	u, ok := s.users[userID]
+	if !ok {
+		writeGraphError(w, graphError(http.StatusBadRequest, 100, 33, fmt.Sprintf("Unsupported get request. Object with ID '%v' does not exist", userID)))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"id":            u.OrgScopedID,
+		"alias":         u.Alias,
+		"org_scoped_id": u.ID,
+	})
+}
+
+func graphError(status, code, subcode int, message string) metaapiwrapper.MetaAPIError {
+	typ := "GraphMethodException"
+	if code == 190 {
+		typ = "OAuthException"
+	}
+
+	return metaapiwrapper.MetaAPIError{
+		StatusCode:   status,
+		Message:      message,
+		Type:         typ,
+		Code:         code,
+		ErrorSubcode: subcode,
+		FBTraceID:    "metaapitest",
+	}
+}
+
+func writeGraphError(w http.ResponseWriter, err metaapiwrapper.MetaAPIError) {
+	if err.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(err.RetryAfter.Seconds()))))
+	}
+	writeJSON(w, err.StatusCode, map[string]interface{}{"error": err})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,71 @@
+package metaapiwrapper
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Option configures the MetaApiRepository built by NewMetaApiRepository
+type Option func(m *metaApiRepositoryImpl)
+
+// WithPlatformConfig sets the app ID and secret used to form the app access token
+func WithPlatformConfig(cfg OCULUSPlatformConfig) Option {
+	return func(m *metaApiRepositoryImpl) {
+		m.AccessToken = cfg
+	}
+}
+
+// WithBaseURL points the repository at another Graph server, e.g. a local fake or staging stand-in
+func WithBaseURL(baseURL string) Option {
+	return func(m *metaApiRepositoryImpl) {
+		m.baseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+// WithHTTPClient reuses an existing client (and its connection pool) for every Graph call
+func WithHTTPClient(client *http.Client) Option {
+	return func(m *metaApiRepositoryImpl) {
+		m.httpClient = client
+	}
+}
+
+// WithRoundTripper replaces the transport of the client used for Graph calls
+func WithRoundTripper(transport http.RoundTripper) Option {
+	return func(m *metaApiRepositoryImpl) {
+		m.transport = transport
+	}
+}
+
+// WithTimeout sets the default timeout of every Graph call
+func WithTimeout(timeout time.Duration) Option {
+	return func(m *metaApiRepositoryImpl) {
+		m.timeout = timeout
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent to Graph
+func WithUserAgent(userAgent string) Option {
+	return func(m *metaApiRepositoryImpl) {
+		m.userAgent = userAgent
+	}
+}
+
+// buildHttpClient merges client, transport and timeout options into the client shared by all calls.
+// A client given by WithHTTPClient is copied so that transport/timeout overrides do not leak back to its owner
+func (m *metaApiRepositoryImpl) buildHttpClient() *http.Client {
+	client := &http.Client{}
+	if m.httpClient != nil {
+		*client = *m.httpClient
+	}
+
+	if m.transport != nil {
+		client.Transport = m.transport
+	}
+
+	if m.timeout > 0 {
+		client.Timeout = m.timeout
+	}
+
+	return client
+}
@@ -0,0 +1,70 @@
+package metaapiwrapper
+
+import (
+	"context"
+	"errors"
+	"iter"
+)
+
+// DefaultMaxItemsOwnedPages is the page guard used when RetrieveItemsOwnedQuery.MaxPages is not set
+const DefaultMaxItemsOwnedPages = 50
+
+// ErrTooManyPages is yielded once a paged walk reaches its MaxPages guard
+var ErrTooManyPages = errors.New("metaapiwrapper: too many pages")
+
+type itemsOwnedPageFunc func(ctx context.Context, q RetrieveItemsOwnedQuery) (RetrieveItemsOwnedResponse, error)
+
+// IterItemsOwned yields every entitlement of viewer_purchases, following paging cursors until Graph reports no next page.
+// Iteration stops at the first error, which is yielded with a zero OculusData
+func (m *metaApiRepositoryImpl) IterItemsOwned(ctx context.Context, q RetrieveItemsOwnedQuery) iter.Seq2[OculusData, error] {
+	return iterItemsOwned(ctx, m.RequestOculusRetrieveItemsOwnedCtx, q)
+}
+
+// AllItemsOwned collects IterItemsOwned into a slice
+func (m *metaApiRepositoryImpl) AllItemsOwned(ctx context.Context, q RetrieveItemsOwnedQuery) (items []OculusData, err error) {
+	return allItemsOwned(m.IterItemsOwned(ctx, q))
+}
+
+func iterItemsOwned(ctx context.Context, page itemsOwnedPageFunc, q RetrieveItemsOwnedQuery) iter.Seq2[OculusData, error] {
+	maxPages := q.MaxPages
+	if maxPages <= 0 {
+		maxPages = DefaultMaxItemsOwnedPages
+	}
+
+	return func(yield func(OculusData, error) bool) {
+		for pages := 0; ; pages++ {
+			if pages >= maxPages {
+				yield(OculusData{}, ErrTooManyPages)
+				return
+			}
+
+			resp, err := page(ctx, q)
+			if err != nil {
+				yield(OculusData{}, err)
+				return
+			}
+
+			for _, data := range resp.Data {
+				if !yield(data, nil) {
+					return
+				}
+			}
+
+			// the last page carries no next link; an empty cursor would restart from the first page
+			if len(resp.Paging.Next) <= 0 || len(resp.Paging.Cursors.After) <= 0 || resp.Paging.Cursors.After == q.After {
+				return
+			}
+			q.After = resp.Paging.Cursors.After
+		}
+	}
+}
+
+func allItemsOwned(seq iter.Seq2[OculusData, error]) (items []OculusData, err error) {
+	for data, iterErr := range seq {
+		if iterErr != nil {
+			return items, iterErr
+		}
+		items = append(items, data)
+	}
+	return
+}
@@ -0,0 +1,104 @@
+package metaapiwrapper_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/hyperbting/api-library/pkg/metaapiwrapper"
+	"github.com/hyperbting/api-library/pkg/metaapiwrapper/metaapitest"
+)
+
+// pagedServer holds n entitlements of u1, served two per page
+func pagedServer(n int) *metaapitest.Server {
+	s := metaapitest.NewServer("app", "secret")
+	s.PageSize = 2
+	for i := 0; i < n; i++ {
+		s.AddEntitlement("u1", metaapitest.Entitlement{SKU: fmt.Sprintf("sku%v", i)})
+	}
+	return s
+}
+
+func TestAllItemsOwnedPages(t *testing.T) {
+	tests := []struct {
+		name     string
+		items    int
+		maxPages int
+		want     int
+		pages    int
+		wantErr  error
+	}{
+		{name: "empty", items: 0, want: 0, pages: 1},
+		{name: "one page", items: 2, want: 2, pages: 1},
+		{name: "partial last page", items: 5, want: 5, pages: 3},
+		{name: "guard reached", items: 7, maxPages: 2, want: 4, pages: 2, wantErr: metaapiwrapper.ErrTooManyPages},
+		{name: "guard exactly met", items: 4, maxPages: 2, want: 4, pages: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := pagedServer(tt.items)
+			defer s.Close()
+
+			repo := metaapiwrapper.NewMetaApiRepository(s.Options()...)
+			items, err := repo.AllItemsOwned(context.Background(), metaapiwrapper.RetrieveItemsOwnedQuery{OrgScopedID: "u1", MaxPages: tt.maxPages})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+			if len(items) != tt.want {
+				t.Errorf("%v items, want %v", len(items), tt.want)
+			}
+			for i, item := range items {
+				if want := fmt.Sprintf("sku%v", i); item.Item.SKU != want {
+					t.Errorf("item %v is %v, want %v", i, item.Item.SKU, want)
+				}
+			}
+			if n := s.Calls(metaapitest.EndpointViewerPurchases); n != tt.pages {
+				t.Errorf("%v pages requested, want %v", n, tt.pages)
+			}
+		})
+	}
+}
+
+func TestIterItemsOwnedEarlyBreak(t *testing.T) {
+	s := pagedServer(6)
+	defer s.Close()
+
+	repo := metaapiwrapper.NewMetaApiRepository(s.Options()...)
+
+	seen := 0
+	for _, err := range repo.IterItemsOwned(context.Background(), metaapiwrapper.RetrieveItemsOwnedQuery{OrgScopedID: "u1"}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen++; seen == 3 {
+			break
+		}
+	}
+
+	// the third item is on the second page; the third page is never fetched
+	if n := s.Calls(metaapitest.EndpointViewerPurchases); n != 2 {
+		t.Errorf("%v pages requested, want 2", n)
+	}
+}
+
+func TestIterItemsOwnedError(t *testing.T) {
+	s := pagedServer(4)
+	defer s.Close()
+	s.InjectError(metaapitest.EndpointViewerPurchases, metaapiwrapper.MetaAPIError{StatusCode: http.StatusBadRequest, Code: 190, Message: "bad token"}, 0)
+
+	repo := metaapiwrapper.NewMetaApiRepository(s.Options()...)
+
+	var errs int
+	for data, err := range repo.IterItemsOwned(context.Background(), metaapiwrapper.RetrieveItemsOwnedQuery{OrgScopedID: "u1"}) {
+		if err == nil || data != (metaapiwrapper.OculusData{}) {
+			t.Fatalf("got %+v, %v; want only the error", data, err)
+		}
+		errs++
+	}
+	if errs != 1 {
+		t.Errorf("%v errors yielded, want 1", errs)
+	}
+}
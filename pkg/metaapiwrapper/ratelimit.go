@@ -0,0 +1,142 @@
+package metaapiwrapper
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned when the client side limit has no token in time, see RateLimit.FailFast
+var ErrRateLimited = errors.New("metaapiwrapper: client side rate limit exceeded")
+
+// RateLimit is a token bucket refilled at Rate tokens per second, holding up to Burst tokens
+type RateLimit struct {
+	// Rate of 0 or less is no limit: a bucket that never refills would refuse every call once Burst is spent
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+	// FailFast returns ErrRateLimited instead of queueing for the next token
+	FailFast bool `json:"fail_fast,omitempty"`
+}
+
+// WithRateLimiter throttles every Graph call of the repository by its app ID
+func WithRateLimiter(limiter *AppRateLimiter) Option {
+	return func(m *metaApiRepositoryImpl) {
+		m.limiter = limiter
+	}
+}
+
+// AppRateLimiter keeps one token bucket per app ID; share one instance between every repository
+// (and service in the process) using the same app so that their calls are counted together
+type AppRateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// NewAppRateLimiter limits the given app IDs; apps without a limit are not throttled
+func NewAppRateLimiter(limits map[string]RateLimit) *AppRateLimiter {
+	l := &AppRateLimiter{
+		buckets: map[string]*tokenBucket{},
+	}
+	for appID, limit := range limits {
+		l.SetLimit(appID, limit)
+	}
+	return l
+}
+
+// SetLimit replaces the limit of appID, starting it with a full bucket; a Rate of 0 or less removes it
+func (l *AppRateLimiter) SetLimit(appID string, limit RateLimit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if limit.Rate <= 0 {
+		delete(l.buckets, appID)
+		return
+	}
+	l.buckets[appID] = newTokenBucket(limit)
+}
+
+// Wait takes a token of appID, queueing until one is available unless the limit is FailFast.
+// It gives up early with ErrRateLimited when the token would come after ctx's deadline
+func (l *AppRateLimiter) Wait(ctx context.Context, appID string) error {
+	l.mu.Lock()
+	bucket, ok := l.buckets[appID]
+	l.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+	return bucket.wait(ctx)
+}
+
+type tokenBucket struct {
+	limit RateLimit
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(limit RateLimit) *tokenBucket {
+	if limit.Burst <= 0 {
+		limit.Burst = 1
+	}
+
+	return &tokenBucket{
+		limit:  limit,
+		tokens: float64(limit.Burst),
+		last:   time.Now(),
+	}
+}
+
+// reserve takes a token, possibly going into debt; delay is how long the caller must wait for it
+func (b *tokenBucket) reserve(now time.Time) (delay time.Duration, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens = min(float64(b.limit.Burst), b.tokens+now.Sub(b.last).Seconds()*b.limit.Rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+
+	if b.limit.FailFast {
+		return 0, false
+	}
+
+	delay = time.Duration((1 - b.tokens) / b.limit.Rate * float64(time.Second))
+	b.tokens--
+	return delay, true
+}
+
+// cancel hands back a token reserved by a caller that stopped waiting
+func (b *tokenBucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens = min(float64(b.limit.Burst), b.tokens+1)
+}
+
+func (b *tokenBucket) wait(ctx context.Context) error {
+	now := time.Now()
+	delay, ok := b.reserve(now)
+	if !ok {
+		return ErrRateLimited
+	}
+	if delay <= 0 {
+		return nil
+	}
+
+	if deadline, hasDeadline := ctx.Deadline(); hasDeadline && deadline.Before(now.Add(delay)) {
+		b.cancel()
+		return ErrRateLimited
+	}
+
+	if err := sleepCtx(ctx, delay); err != nil {
+		b.cancel()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package metaapiwrapper_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hyperbting/api-library/pkg/metaapiwrapper"
+	"github.com/hyperbting/api-library/pkg/metaapiwrapper/metaapitest"
+)
+
+func TestRateLimiterBurst(t *testing.T) {
+	l := metaapiwrapper.NewAppRateLimiter(map[string]metaapiwrapper.RateLimit{
+		"app": {Rate: 0.1, Burst: 3, FailFast: true},
+	})
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if err := l.Wait(ctx, "app"); err != nil {
+			t.Fatalf("token %v: %v", i, err)
+		}
+	}
+	if err := l.Wait(ctx, "app"); !errors.Is(err, metaapiwrapper.ErrRateLimited) {
+		t.Errorf("past the burst: got %v, want ErrRateLimited", err)
+	}
+
+	// other apps have their own bucket, or none
+	if err := l.Wait(ctx, "other"); err != nil {
+		t.Errorf("unlimited app: %v", err)
+	}
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	// one token every 20ms
+	l := metaapiwrapper.NewAppRateLimiter(map[string]metaapiwrapper.RateLimit{"app": {Rate: 50, Burst: 1}})
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := l.Wait(ctx, "app"); err != nil {
+			t.Fatalf("token %v: %v", i, err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("3 tokens in %v, want the last two to wait for the refill", elapsed)
+	}
+}
+
+func TestRateLimiterNoRate(t *testing.T) {
+	// a burst alone never refills; it is treated as no limit rather than refusing every call once spent
+	l := metaapiwrapper.NewAppRateLimiter(map[string]metaapiwrapper.RateLimit{"app": {Burst: 2}})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	for i := 0; i < 5; i++ {
+		if err := l.Wait(ctx, "app"); err != nil {
+			t.Fatalf("call %v: %v", i, err)
+		}
+	}
+
+	// and removes a limit set before
+	l.SetLimit("other", metaapiwrapper.RateLimit{Rate: 0.1, Burst: 1, FailFast: true})
+	l.SetLimit("other", metaapiwrapper.RateLimit{})
+	for i := 0; i < 3; i++ {
+		if err := l.Wait(ctx, "other"); err != nil {
+			t.Fatalf("removed limit, call %v: %v", i, err)
+		}
+	}
+}
+
+func TestRateLimiterDeadlineTooShort(t *testing.T) {
+	l := metaapiwrapper.NewAppRateLimiter(map[string]metaapiwrapper.RateLimit{"app": {Rate: 1, Burst: 1}})
+	if err := l.Wait(context.Background(), "app"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the next token is a second away, past the deadline: give up now instead of sleeping until it
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := l.Wait(ctx, "app"); !errors.Is(err, metaapiwrapper.ErrRateLimited) {
+		t.Fatalf("got %v, want ErrRateLimited", err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("returned after %v, want right away", elapsed)
+	}
+}
+
+func TestRateLimiterCancelReturnsToken(t *testing.T) {
+	// one token every 100ms
+	l := metaapiwrapper.NewAppRateLimiter(map[string]metaapiwrapper.RateLimit{"app": {Rate: 10, Burst: 1}})
+	if err := l.Wait(context.Background(), "app"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if err := l.Wait(ctx, "app"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+
+	// the abandoned reservation is handed back: the next token is under 100ms away, not 200ms
+	ctx, cancel = context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx, "app"); err != nil {
+		t.Errorf("after the cancellation: %v", err)
+	}
+}
+
+func TestRateLimiterRepository(t *testing.T) {
+	s := metaapitest.NewServer("app", "secret")
+	defer s.Close()
+	s.AddEntitlement("u1", metaapitest.Entitlement{SKU: "sku"})
+
+	limiter := metaapiwrapper.NewAppRateLimiter(map[string]metaapiwrapper.RateLimit{"app": {Rate: 0.1, Burst: 2, FailFast: true}})
+	// repositories sharing the limiter share the bucket
+	repos := []metaapiwrapper.MetaApiRepository{
+		metaapiwrapper.NewMetaApiRepository(append(s.Options(), metaapiwrapper.WithRateLimiter(limiter))...),
+		metaapiwrapper.NewMetaApiRepository(append(s.Options(), metaapiwrapper.WithRateLimiter(limiter))...),
+	}
+	ctx := context.Background()
+	q := metaapiwrapper.VerifyItemOwnershipQuery{UsrID: "u1", SKU: "sku"}
+
+	for i, repo := range repos {
+		if _, err := repo.RequestOculusVerifyItemOwnershipCtx(ctx, q); err != nil {
+			t.Fatalf("repository %v: %v", i, err)
+		}
+	}
+	if _, err := repos[0].RequestOculusVerifyItemOwnershipCtx(ctx, q); !errors.Is(err, metaapiwrapper.ErrRateLimited) {
+		t.Errorf("got %v, want ErrRateLimited", err)
+	}
+	if n := s.Calls(metaapitest.EndpointVerifyEntitlement); n != 2 {
+		t.Errorf("%v Graph calls, want 2", n)
+	}
+}
@@ -0,0 +1,116 @@
+package metaapiwrapper_test
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hyperbting/api-library/pkg/metaapiwrapper"
+	"github.com/hyperbting/api-library/pkg/metaapiwrapper/metaapitest"
+)
+
+var updateGolden = flag.Bool("update", false, "record the golden files in testdata against metaapitest.Server")
+
+// goldenRepository replays testdata/name, or records it from a fake server seeded by seed when -update is set
+func goldenRepository(t *testing.T, name string, seed func(s *metaapitest.Server)) (metaapiwrapper.MetaApiRepository, *metaapitest.Recorder) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+
+	if !*updateGolden {
+		rec, err := metaapitest.NewRecorder(path, metaapitest.ModeReplay, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			if unused := rec.Unused(); len(unused) > 0 {
+				t.Errorf("%v recorded interactions were not requested: %+v", len(unused), unused)
+			}
+		})
+		return metaapiwrapper.NewMetaApiRepository(
+			metaapiwrapper.WithPlatformConfig(metaapiwrapper.OCULUSPlatformConfig{AppID: "app", AppSecret: "secret"}),
+			rec.Option(),
+		), rec
+	}
+
+	s := metaapitest.NewServer("app", "secret")
+	t.Cleanup(s.Close)
+	seed(s)
+
+	rec, err := metaapitest.NewRecorder(path, metaapitest.ModeRecord, s.Client().Transport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := rec.Save(); err != nil {
+			t.Error(err)
+		}
+	})
+	return metaapiwrapper.NewMetaApiRepository(
+		metaapiwrapper.WithBaseURL(s.URL),
+		metaapiwrapper.WithPlatformConfig(s.PlatformConfig()),
+		rec.Option(),
+	), rec
+}
+
+func TestRetrieveItemsOwnedGolden(t *testing.T) {
+	repo, _ := goldenRepository(t, "viewer_purchases.json", func(s *metaapitest.Server) {
+		s.PageSize = 2
+		s.AddUser(metaapitest.User{ID: "u1", OrgScopedID: "org1"})
+		for _, sku := range []string{"sword", "shield", "potion"} {
+			s.AddEntitlement("u1", metaapitest.Entitlement{SKU: sku})
+		}
+	})
+	ctx := context.Background()
+	q := metaapiwrapper.RetrieveItemsOwnedQuery{OrgScopedID: "org1"}
+
+	page, err := repo.RequestOculusRetrieveItemsOwnedCtx(ctx, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Data) != 2 || page.Data[0].Item.SKU != "sword" || len(page.Paging.Cursors.After) <= 0 {
+		t.Errorf("first page %+v", page)
+	}
+
+	items, err := repo.AllItemsOwned(ctx, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var skus []string
+	for _, item := range items {
+		skus = append(skus, item.Item.SKU)
+	}
+	if strings.Join(skus, ",") != "sword,shield,potion" {
+		t.Errorf("got %v", skus)
+	}
+
+	if *updateGolden {
+		return
+	}
+	if _, err = repo.RequestOculusRetrieveItemsOwnedCtx(ctx, metaapiwrapper.RetrieveItemsOwnedQuery{OrgScopedID: "org2"}); !errors.Is(err, metaapitest.ErrNoInteraction) {
+		t.Errorf("unrecorded request: got %v, want ErrNoInteraction", err)
+	}
+}
+
+func TestGoldenFilesScrubbed(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := metaapiwrapper.OCULUSPlatformConfig{AppID: "app", AppSecret: "secret"}
+	token := cfg.FormAccessToken()
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), token) || strings.Contains(string(data), url.QueryEscape(token)) {
+			t.Errorf("%v contains the app access token", path)
+		}
+	}
+}
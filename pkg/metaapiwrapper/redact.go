@@ -0,0 +1,54 @@
+package metaapiwrapper
+
+import (
+	"io"
+	"log/slog"
+	"net/url"
+	"regexp"
+)
+
+const redacted = "REDACTED"
+
+var (
+	discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	// OC|app_id|app_secret, plain or query escaped
+	accessTokenPattern = regexp.MustCompile(`(OC(?:\||%7[cC])[^|%&\s"]+(?:\||%7[cC]))[^&\s"]+`)
+
+	// query parameters that must never reach a log line
+	sensitiveParams = []string{"access_token", "nonce"}
+)
+
+// WithLogger routes request/response logging to logger; nothing is logged by default.
+// Access tokens, app secrets and nonces are redacted before logging
+func WithLogger(logger *slog.Logger) Option {
+	return func(m *metaApiRepositoryImpl) {
+		if logger == nil {
+			logger = discardLogger
+		}
+		m.logger = logger
+	}
+}
+
+// RedactAccessToken replaces the secret part of every OC|app_id|app_secret token in s
+func RedactAccessToken(s string) string {
+	return accessTokenPattern.ReplaceAllString(s, "${1}"+redacted)
+}
+
+// redactURL renders u with the sensitive query parameters replaced
+func redactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+
+	clean := *u
+	query := clean.Query()
+	for _, key := range sensitiveParams {
+		if query.Has(key) {
+			query.Set(key, redacted)
+		}
+	}
+	clean.RawQuery = query.Encode()
+
+	return RedactAccessToken(clean.String())
+}
@@ -0,0 +1,117 @@
+package metaapiwrapper_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/hyperbting/api-library/pkg/metaapiwrapper"
+	"github.com/hyperbting/api-library/pkg/metaapiwrapper/metaapitest"
+)
+
+const (
+	redactSecret = "s3cr3t-app-secret"
+	redactNonce  = "n0nce-from-headset"
+)
+
+// assertRedacted fails when text holds the secret or the nonce, plain or query escaped
+func assertRedacted(t *testing.T, what, text string) {
+	t.Helper()
+
+	for _, leak := range []string{redactSecret, redactNonce, url.QueryEscape("OC|app|" + redactSecret), "%7C" + redactSecret, "%7c" + redactSecret} {
+		if strings.Contains(text, leak) {
+			t.Errorf("%v leaks %q:\n%v", what, leak, text)
+		}
+	}
+}
+
+func TestLoggingRedactsSecrets(t *testing.T) {
+	for name, newHandler := range map[string]func(buf *bytes.Buffer) slog.Handler{
+		"text": func(buf *bytes.Buffer) slog.Handler {
+			return slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})
+		},
+		"json": func(buf *bytes.Buffer) slog.Handler {
+			return slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := metaapitest.NewServer("app", redactSecret)
+			defer s.Close()
+			s.AddUser(metaapitest.User{ID: "u1", OrgScopedID: "org1"})
+			s.AddNonce("u1", redactNonce)
+			s.AddEntitlement("u1", metaapitest.Entitlement{SKU: "sku"})
+
+			var buf bytes.Buffer
+			logger := slog.New(newHandler(&buf))
+			repo := metaapiwrapper.NewMetaApiRepository(append(s.Options(), metaapiwrapper.WithLogger(logger))...)
+			ctx := context.Background()
+
+			if _, err := repo.RequestOculusUserNonceValidateCtx(ctx, metaapiwrapper.UserNonceValidateQuery{UserID: "u1", Nonce: redactNonce}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := repo.AllItemsOwned(ctx, metaapiwrapper.RetrieveItemsOwnedQuery{OrgScopedID: "u1"}); err != nil {
+				t.Fatal(err)
+			}
+
+			s.InjectError(metaapitest.EndpointVerifyEntitlement, metaapiwrapper.MetaAPIError{StatusCode: http.StatusBadRequest, Code: 190, Message: "Invalid OAuth access token"}, 1)
+			_, err := repo.RequestOculusVerifyItemOwnershipCtx(ctx, metaapiwrapper.VerifyItemOwnershipQuery{UsrID: "u1", SKU: "sku"})
+			if err == nil {
+				t.Fatal("the injected error was not returned")
+			}
+			assertRedacted(t, "graph error", err.Error())
+
+			logger.Info("configured", "platform", s.PlatformConfig())
+
+			log := buf.String()
+			if !strings.Contains(log, "metaapiwrapper request") || !strings.Contains(log, "metaapiwrapper graph error") {
+				t.Fatalf("requests were not logged:\n%v", log)
+			}
+			assertRedacted(t, "log", log)
+		})
+	}
+}
+
+func TestTransportErrorRedacted(t *testing.T) {
+	// a closed server makes the transport fail with a *url.Error that carries the request URL
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	var buf bytes.Buffer
+	repo := metaapiwrapper.NewMetaApiRepository(
+		metaapiwrapper.WithBaseURL(closed.URL),
+		metaapiwrapper.WithPlatformConfig(metaapiwrapper.OCULUSPlatformConfig{AppID: "app", AppSecret: redactSecret}),
+		metaapiwrapper.WithLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+	)
+
+	_, err := repo.RequestOculusUserNonceValidateCtx(context.Background(), metaapiwrapper.UserNonceValidateQuery{UserID: "u1", Nonce: redactNonce})
+	if err == nil {
+		t.Fatal("request to a closed server succeeded")
+	}
+	assertRedacted(t, "transport error", err.Error())
+	if !strings.Contains(buf.String(), "metaapiwrapper request failed") {
+		t.Fatalf("failure was not logged:\n%v", buf.String())
+	}
+	assertRedacted(t, "log", buf.String())
+}
+
+func TestRedactAccessToken(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"OC|app|" + redactSecret, "OC|app|REDACTED"},
+		{"access_token=OC%7Capp%7C" + redactSecret + "&user_id=u1", "access_token=OC%7Capp%7CREDACTED&user_id=u1"},
+		{"access_token=OC%7capp%7c" + redactSecret, "access_token=OC%7capp%7cREDACTED"},
+		{`{"next":"https://graph.oculus.com/app?access_token=OC|app|` + redactSecret + `"}`, `{"next":"https://graph.oculus.com/app?access_token=OC|app|REDACTED"}`},
+		{"no token here", "no token here"},
+	}
+	for _, tt := range tests {
+		if got := metaapiwrapper.RedactAccessToken(tt.in); got != tt.want {
+			t.Errorf("RedactAccessToken(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
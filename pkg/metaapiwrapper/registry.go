@@ -0,0 +1,177 @@
+package metaapiwrapper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrUnknownApp is returned by Registry.Get for an app ID or slug that was never registered
+var ErrUnknownApp = errors.New("metaapiwrapper: unknown app")
+
+// Duration reads "90s"/"5m" style strings from config
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// AppCacheConfig enables a CachedMetaApiRepository for one app
+type AppCacheConfig struct {
+	Size        int      `json:"size,omitempty"`
+	PositiveTTL Duration `json:"positive_ttl,omitempty"`
+	NegativeTTL Duration `json:"negative_ttl,omitempty"`
+}
+
+// AppConfig configures one title of a Registry
+type AppConfig struct {
+	// Slug is an optional second key, e.g. "fitness"
+	Slug  string `json:"slug,omitempty"`
+	AppID string `json:"app_id"`
+
+	AppSecret string `json:"app_secret,omitempty"`
+	// AppSecretEnv names the environment variable holding the secret when AppSecret is empty
+	AppSecretEnv string `json:"app_secret_env,omitempty"`
+
+	RateLimit *RateLimit      `json:"rate_limit,omitempty"`
+	Cache     *AppCacheConfig `json:"cache,omitempty"`
+}
+
+// RegistryConfig is the file format read by LoadRegistry
+type RegistryConfig struct {
+	Apps []AppConfig `json:"apps"`
+}
+
+// Registry hands out one configured repository per app, for backends serving several titles.
+// Options given to the registry (client, logger, retry, instrumentation...) are shared by every app
+type Registry struct {
+	opts    []Option
+	limiter *AppRateLimiter
+
+	mu      sync.RWMutex
+	byAppID map[string]MetaApiRepository
+	slugs   map[string]string
+}
+
+// NewRegistry registers apps with opts applied to each of them
+func NewRegistry(apps []AppConfig, opts ...Option) (*Registry, error) {
+	r := &Registry{
+		opts:    opts,
+		limiter: NewAppRateLimiter(nil),
+		byAppID: map[string]MetaApiRepository{},
+		slugs:   map[string]string{},
+	}
+
+	for _, app := range apps {
+		if err := r.Register(app); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// LoadRegistry builds a Registry from a JSON RegistryConfig
+func LoadRegistry(reader io.Reader, opts ...Option) (*Registry, error) {
+	var cfg RegistryConfig
+	if err := json.NewDecoder(reader).Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("metaapiwrapper: registry config: %w", err)
+	}
+
+	return NewRegistry(cfg.Apps, opts...)
+}
+
+// Register adds an app; app IDs and slugs must be unique across the registry
+func (r *Registry) Register(app AppConfig) error {
+	if len(app.AppID) <= 0 {
+		return errors.New("metaapiwrapper: app_id is required")
+	}
+
+	secret := app.AppSecret
+	if len(secret) <= 0 && len(app.AppSecretEnv) > 0 {
+		secret = os.Getenv(app.AppSecretEnv)
+	}
+	if len(secret) <= 0 {
+		return fmt.Errorf("metaapiwrapper: no secret for app %v", app.AppID)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.byAppID[app.AppID]; ok {
+		return fmt.Errorf("metaapiwrapper: app %v registered twice", app.AppID)
+	}
+	if _, ok := r.slugs[app.Slug]; ok && len(app.Slug) > 0 {
+		return fmt.Errorf("metaapiwrapper: slug %v registered twice", app.Slug)
+	}
+
+	opts := append([]Option{}, r.opts...)
+	opts = append(opts, WithPlatformConfig(OCULUSPlatformConfig{AppID: app.AppID, AppSecret: secret}))
+	if app.RateLimit != nil {
+		r.limiter.SetLimit(app.AppID, *app.RateLimit)
+		opts = append(opts, WithRateLimiter(r.limiter))
+	}
+
+	repo := NewMetaApiRepository(opts...)
+	if app.Cache != nil {
+		repo = NewCachedMetaApiRepository(repo, CacheConfig{
+			PositiveTTL: time.Duration(app.Cache.PositiveTTL),
+			NegativeTTL: time.Duration(app.Cache.NegativeTTL),
+			Backend:     NewLRUEntitlementCache(app.Cache.Size),
+		})
+	}
+
+	r.byAppID[app.AppID] = repo
+	if len(app.Slug) > 0 {
+		r.slugs[app.Slug] = app.AppID
+	}
+	return nil
+}
+
+// Get returns the repository of an app ID or slug
+func (r *Registry) Get(key string) (MetaApiRepository, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if appID, ok := r.slugs[key]; ok {
+		key = appID
+	}
+
+	repo, ok := r.byAppID[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrUnknownApp, key)
+	}
+	return repo, nil
+}
+
+// AppIDs lists the registered app IDs, sorted
+func (r *Registry) AppIDs() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	appIDs := make([]string, 0, len(r.byAppID))
+	for appID := range r.byAppID {
+		appIDs = append(appIDs, appID)
+	}
+	sort.Strings(appIDs)
+
+	return appIDs
+}
@@ -0,0 +1,218 @@
+package metaapiwrapper_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperbting/api-library/pkg/metaapiwrapper"
+	"github.com/hyperbting/api-library/pkg/metaapiwrapper/metaapitest"
+)
+
+func TestRegistryRegister(t *testing.T) {
+	t.Setenv("METAAPI_TEST_SECRET", "from-env")
+
+	tests := []struct {
+		name     string
+		apps     []metaapiwrapper.AppConfig
+		errMatch string
+	}{
+		{name: "secret", apps: []metaapiwrapper.AppConfig{{AppID: "a", AppSecret: "s"}}},
+		{name: "secret from env", apps: []metaapiwrapper.AppConfig{{AppID: "a", AppSecretEnv: "METAAPI_TEST_SECRET"}}},
+		{name: "empty env", apps: []metaapiwrapper.AppConfig{{AppID: "a", AppSecretEnv: "METAAPI_TEST_UNSET"}}, errMatch: "no secret for app a"},
+		{name: "no secret", apps: []metaapiwrapper.AppConfig{{AppID: "a"}}, errMatch: "no secret for app a"},
+		{name: "no app ID", apps: []metaapiwrapper.AppConfig{{AppSecret: "s"}}, errMatch: "app_id is required"},
+		{
+			name:     "duplicate app ID",
+			apps:     []metaapiwrapper.AppConfig{{AppID: "a", Slug: "one", AppSecret: "s"}, {AppID: "a", Slug: "two", AppSecret: "s"}},
+			errMatch: "app a registered twice",
+		},
+		{
+			name:     "duplicate slug",
+			apps:     []metaapiwrapper.AppConfig{{AppID: "a", Slug: "one", AppSecret: "s"}, {AppID: "b", Slug: "one", AppSecret: "s"}},
+			errMatch: "slug one registered twice",
+		},
+		{
+			name: "apps without slugs",
+			apps: []metaapiwrapper.AppConfig{{AppID: "a", AppSecret: "s"}, {AppID: "b", AppSecret: "s"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := metaapiwrapper.NewRegistry(tt.apps)
+			if len(tt.errMatch) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.errMatch) {
+					t.Fatalf("got %v, want an error containing %q", err, tt.errMatch)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := len(r.AppIDs()); got != len(tt.apps) {
+				t.Errorf("%v apps registered, want %v", got, len(tt.apps))
+			}
+		})
+	}
+}
+
+func TestRegistrySecretFromEnv(t *testing.T) {
+	s := metaapitest.NewServer("app", "secret")
+	defer s.Close()
+	s.AddEntitlement("u1", metaapitest.Entitlement{SKU: "sku"})
+	t.Setenv("METAAPI_TEST_SECRET", "secret")
+
+	r, err := metaapiwrapper.NewRegistry([]metaapiwrapper.AppConfig{
+		{AppID: "app", AppSecretEnv: "METAAPI_TEST_SECRET"},
+	}, metaapiwrapper.WithBaseURL(s.URL), metaapiwrapper.WithHTTPClient(s.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := r.Get("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the fake server only accepts the token formed with the secret it was given
+	if _, err := repo.RequestOculusVerifyItemOwnershipCtx(context.Background(), metaapiwrapper.VerifyItemOwnershipQuery{UsrID: "u1", SKU: "sku"}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLoadRegistry(t *testing.T) {
+	r, err := metaapiwrapper.LoadRegistry(strings.NewReader(`{"apps": [
+		{"app_id": "111", "slug": "fitness", "app_secret": "s1", "cache": {"size": 10, "positive_ttl": "90s", "negative_ttl": "5m"}},
+		{"app_id": "222", "app_secret": "s2", "rate_limit": {"rate": 5, "burst": 10}}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := r.AppIDs(); len(got) != 2 || got[0] != "111" || got[1] != "222" {
+		t.Errorf("app IDs %v", got)
+	}
+
+	bySlug, err := r.Get("fitness")
+	if err != nil {
+		t.Fatal(err)
+	}
+	byAppID, err := r.Get("111")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bySlug != byAppID {
+		t.Error("the slug and the app ID give different repositories")
+	}
+	if _, ok := bySlug.(*metaapiwrapper.CachedMetaApiRepository); !ok {
+		t.Errorf("app with a cache config is a %T", bySlug)
+	}
+	if repo, _ := r.Get("222"); repo == nil {
+		t.Error("app without a slug is not found by app ID")
+	} else if _, ok := repo.(*metaapiwrapper.CachedMetaApiRepository); ok {
+		t.Error("app without a cache config is cached")
+	}
+
+	if _, err := r.Get("nope"); !errors.Is(err, metaapiwrapper.ErrUnknownApp) {
+		t.Errorf("got %v, want ErrUnknownApp", err)
+	}
+
+	for _, bad := range []string{`{"apps": [{"app_id": "1", "app_secret": "s", "cache": {"positive_ttl": "soon"}}]}`, `{"apps": `} {
+		if _, err := metaapiwrapper.LoadRegistry(strings.NewReader(bad)); err == nil {
+			t.Errorf("%v: no error", bad)
+		}
+	}
+}
+
+func TestRegistryWiring(t *testing.T) {
+	s := metaapitest.NewServer("app", "secret")
+	defer s.Close()
+	s.AddEntitlement("u1", metaapitest.Entitlement{SKU: "sku"})
+
+	r, err := metaapiwrapper.NewRegistry([]metaapiwrapper.AppConfig{
+		{AppID: "app", AppSecret: "secret", Cache: &metaapiwrapper.AppCacheConfig{Size: 10, PositiveTTL: metaapiwrapper.Duration(time.Minute)}},
+		{AppID: "limited", AppSecret: "other", RateLimit: &metaapiwrapper.RateLimit{Rate: 0.1, Burst: 1, FailFast: true}},
+		{AppID: "free", AppSecret: "other"},
+	}, metaapiwrapper.WithBaseURL(s.URL), metaapiwrapper.WithHTTPClient(s.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	verify := func(appID string) error {
+		repo, err := r.Get(appID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = repo.RequestOculusVerifyItemOwnershipCtx(ctx, metaapiwrapper.VerifyItemOwnershipQuery{UsrID: "u1", SKU: "sku"})
+		return err
+	}
+
+	// the cached app asks Graph once
+	for i := 0; i < 3; i++ {
+		if err := verify("app"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := s.Calls(metaapitest.EndpointVerifyEntitlement); n != 1 {
+		t.Errorf("cached app: %v Graph calls, want 1", n)
+	}
+
+	// the fake server rejects the token of the other apps, but only the limited one is throttled
+	if err := verify("limited"); errors.Is(err, metaapiwrapper.ErrRateLimited) {
+		t.Errorf("first call of the limited app: %v", err)
+	}
+	if err := verify("limited"); !errors.Is(err, metaapiwrapper.ErrRateLimited) {
+		t.Errorf("second call of the limited app: got %v, want ErrRateLimited", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := verify("free"); errors.Is(err, metaapiwrapper.ErrRateLimited) {
+			t.Errorf("unlimited app: %v", err)
+		}
+	}
+	if n := s.Calls(metaapitest.EndpointVerifyEntitlement); n != 5 {
+		t.Errorf("%v Graph calls, want 5", n)
+	}
+}
+
+func TestDurationJSON(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{in: `"90s"`, want: 90 * time.Second},
+		{in: `"5m"`, want: 5 * time.Minute},
+		{in: `"1h30m"`, want: 90 * time.Minute},
+		{in: `"0s"`, want: 0},
+		{in: `"soon"`, wantErr: true},
+		{in: `90`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		var d metaapiwrapper.Duration
+		err := json.Unmarshal([]byte(tt.in), &d)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%v: error %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if time.Duration(d) != tt.want {
+			t.Errorf("%v: got %v, want %v", tt.in, time.Duration(d), tt.want)
+		}
+
+		// and back to the same duration
+		out, err := json.Marshal(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var back metaapiwrapper.Duration
+		if err := json.Unmarshal(out, &back); err != nil || back != d {
+			t.Errorf("%v: marshalled to %s, read back %v, %v", tt.in, out, time.Duration(back), err)
+		}
+	}
+}
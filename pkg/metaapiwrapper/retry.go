@@ -0,0 +1,116 @@
+package metaapiwrapper
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"reflect"
+	"time"
+)
+
+// RetryPolicy controls how idempotent Graph calls (nonce validation, ownership, purchases, org scoped ID) are retried.
+// Consuming an entitlement is never retried
+type RetryPolicy struct {
+	// MaxAttempts counts the first call too; 0 or 1 disables retry
+	MaxAttempts int
+	// BaseDelay is doubled on every attempt, with jitter, up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Retryable classifies errors, IsTransient when nil
+	Retryable func(err error) bool
+}
+
+// DefaultRetryPolicy is a reasonable policy for login paths
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   200 * time.Millisecond,
+	MaxDelay:    5 * time.Second,
+}
+
+// WithRetryPolicy enables retry of idempotent Graph calls
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(m *metaApiRepositoryImpl) {
+		m.retry = policy
+	}
+}
+
+// backoff returns how long to wait before attempt+1, or false when err should not be retried.
+// A Retry-After from Graph takes precedence; one longer than MaxDelay ends the retries
+func (p RetryPolicy) backoff(attempt int, err error) (delay time.Duration, ok bool) {
+	if attempt >= p.MaxAttempts {
+		return 0, false
+	}
+
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = IsTransient
+	}
+	if !retryable(err) {
+		return 0, false
+	}
+
+	var apiErr *MetaAPIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		if p.MaxDelay > 0 && apiErr.RetryAfter > p.MaxDelay {
+			return 0, false
+		}
+		return apiErr.RetryAfter, true
+	}
+
+	delay = p.BaseDelay << (attempt - 1)
+	if delay <= 0 || (p.MaxDelay > 0 && delay > p.MaxDelay) {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0, true
+	}
+
+	// equal jitter keeps at least half of the backoff while spreading retries of concurrent callers
+	half := delay / 2
+	return half + rand.N(delay-half+1), true
+}
+
+// doJSONRetry is doJSON for idempotent calls, retried according to m.retry
+func (m *metaApiRepositoryImpl) doJSONRetry(req *http.Request, out interface{}) (status int, attempts int, err error) {
+	ctx := req.Context()
+	for attempts = 1; ; attempts++ {
+		// drop whatever a failed attempt decoded into out
+		reflect.ValueOf(out).Elem().SetZero()
+
+		if status, err = m.doJSON(req.Clone(ctx), out); err == nil {
+			return
+		}
+
+		// the caller gave up; only timeouts of the attempt itself are worth another one
+		if ctx.Err() != nil {
+			return
+		}
+
+		delay, ok := m.retry.backoff(attempts, err)
+		if !ok {
+			return
+		}
+
+		if sleepErr := sleepCtx(ctx, delay); sleepErr != nil {
+			err = sleepErr
+			return
+		}
+	}
+}
+
+func sleepCtx(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,127 @@
+package metaapiwrapper_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hyperbting/api-library/pkg/metaapiwrapper"
+	"github.com/hyperbting/api-library/pkg/metaapiwrapper/metaapitest"
+)
+
+var testRetryPolicy = metaapiwrapper.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+
+func TestRetryTransientGraphError(t *testing.T) {
+	s := metaapitest.NewServer("app", "secret")
+	defer s.Close()
+	s.AddEntitlement("u1", metaapitest.Entitlement{SKU: "sku"})
+	s.InjectError(metaapitest.EndpointVerifyEntitlement, metaapiwrapper.MetaAPIError{StatusCode: http.StatusServiceUnavailable, Code: 2, Message: "down"}, 2)
+
+	repo := metaapiwrapper.NewMetaApiRepository(append(s.Options(), metaapiwrapper.WithRetryPolicy(testRetryPolicy))...)
+
+	resp, err := repo.RequestOculusVerifyItemOwnershipCtx(context.Background(), metaapiwrapper.VerifyItemOwnershipQuery{UsrID: "u1", SKU: "sku"})
+	if err != nil || !resp.Success {
+		t.Fatalf("got %+v, %v", resp, err)
+	}
+	if n := s.Calls(metaapitest.EndpointVerifyEntitlement); n != 3 {
+		t.Errorf("%v calls, want 3", n)
+	}
+}
+
+func TestRetryPermanentError(t *testing.T) {
+	s := metaapitest.NewServer("app", "secret")
+	defer s.Close()
+	s.InjectError(metaapitest.EndpointVerifyEntitlement, metaapiwrapper.MetaAPIError{StatusCode: http.StatusBadRequest, Code: 190, Message: "bad token"}, 0)
+
+	repo := metaapiwrapper.NewMetaApiRepository(append(s.Options(), metaapiwrapper.WithRetryPolicy(testRetryPolicy))...)
+
+	_, err := repo.RequestOculusVerifyItemOwnershipCtx(context.Background(), metaapiwrapper.VerifyItemOwnershipQuery{UsrID: "u1", SKU: "sku"})
+	if !metaapiwrapper.IsInvalidToken(err) {
+		t.Fatalf("got %v, want invalid token", err)
+	}
+	if n := s.Calls(metaapitest.EndpointVerifyEntitlement); n != 1 {
+		t.Errorf("%v calls, want 1", n)
+	}
+}
+
+func TestRetryConsumeNotRetried(t *testing.T) {
+	s := metaapitest.NewServer("app", "secret")
+	defer s.Close()
+	s.AddEntitlement("u1", metaapitest.Entitlement{SKU: "sku", Consumable: true})
+	s.InjectError(metaapitest.EndpointConsumeEntitlement, metaapiwrapper.MetaAPIError{StatusCode: http.StatusInternalServerError, Code: 2, Message: "down"}, 1)
+
+	repo := metaapiwrapper.NewMetaApiRepository(append(s.Options(), metaapiwrapper.WithRetryPolicy(testRetryPolicy))...)
+
+	q := metaapiwrapper.OculusConsumeIAPItemQuery{VerifyItemOwnershipQuery: metaapiwrapper.VerifyItemOwnershipQuery{UsrID: "u1", SKU: "sku"}}
+	if _, err := repo.RequestOculusConsumeIAPItemCtx(context.Background(), q); !metaapiwrapper.IsTransient(err) {
+		t.Fatalf("got %v, want the transient error", err)
+	}
+	if n := s.Calls(metaapitest.EndpointConsumeEntitlement); n != 1 {
+		t.Errorf("%v calls, want 1", n)
+	}
+}
+
+// slowServer answers verify_entitlement after delay(call), or when the client gives up
+func slowServer(delay func(call int32) time.Duration) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay(calls.Add(1))):
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"success":true}`))
+		case <-r.Context().Done():
+		}
+	}))
+	return s, &calls
+}
+
+func TestRetryAttemptTimeout(t *testing.T) {
+	s, calls := slowServer(func(call int32) time.Duration {
+		if call == 1 {
+			return time.Second
+		}
+		return 0
+	})
+	defer s.Close()
+
+	repo := metaapiwrapper.NewMetaApiRepository(
+		metaapiwrapper.WithBaseURL(s.URL),
+		metaapiwrapper.WithPlatformConfig(metaapiwrapper.OCULUSPlatformConfig{AppID: "app", AppSecret: "secret"}),
+		metaapiwrapper.WithTimeout(50*time.Millisecond),
+		metaapiwrapper.WithRetryPolicy(testRetryPolicy),
+	)
+
+	resp, err := repo.RequestOculusVerifyItemOwnershipCtx(context.Background(), metaapiwrapper.VerifyItemOwnershipQuery{UsrID: "u1", SKU: "sku"})
+	if err != nil || !resp.Success {
+		t.Fatalf("got %+v, %v", resp, err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("%v calls, want 2", n)
+	}
+}
+
+func TestRetryCallerDeadline(t *testing.T) {
+	s, calls := slowServer(func(int32) time.Duration { return time.Second })
+	defer s.Close()
+
+	repo := metaapiwrapper.NewMetaApiRepository(
+		metaapiwrapper.WithBaseURL(s.URL),
+		metaapiwrapper.WithPlatformConfig(metaapiwrapper.OCULUSPlatformConfig{AppID: "app", AppSecret: "secret"}),
+		metaapiwrapper.WithRetryPolicy(testRetryPolicy),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := repo.RequestOculusVerifyItemOwnershipCtx(ctx, metaapiwrapper.VerifyItemOwnershipQuery{UsrID: "u1", SKU: "sku"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want deadline exceeded", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("%v calls, want 1", n)
+	}
+}
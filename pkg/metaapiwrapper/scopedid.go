@@ -0,0 +1,212 @@
+package metaapiwrapper
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// AppScopedID is the user ID an app sees, e.g. the one reported by the headset and validated with a nonce
+type AppScopedID string
+
+// OrgScopedID is the user ID shared by every app of the organization
+type OrgScopedID string
+
+// AppScopedID is the queried user ID, which Graph returns in org_scoped_id
+func (r *GetOculusOrgScopedIDResponse) AppScopedID() AppScopedID {
+	return AppScopedID(r.ScopedID)
+}
+
+// OrgScopedID is the resolved org scoped ID, which Graph returns in id
+func (r *GetOculusOrgScopedIDResponse) OrgScopedID() OrgScopedID {
+	return OrgScopedID(r.ID)
+}
+
+// ScopedIDMapping pairs an app scoped ID with its org scoped ID
+type ScopedIDMapping struct {
+	AppScopedID AppScopedID
+	OrgScopedID OrgScopedID
+	ResolvedAt  time.Time
+}
+
+// ScopedIDStore persists resolved mappings; the pair never changes once Graph resolved it
+type ScopedIDStore interface {
+	// Lookup returns the mapping of appScopedID, ok is false when it is unknown
+	Lookup(ctx context.Context, appScopedID AppScopedID) (mapping ScopedIDMapping, ok bool, err error)
+	// LookupOrg returns every known app scoped ID of orgScopedID
+	LookupOrg(ctx context.Context, orgScopedID OrgScopedID) (mappings []ScopedIDMapping, err error)
+	Save(ctx context.Context, mapping ScopedIDMapping) error
+}
+
+// ScopedIDResolver resolves org scoped IDs from its store first and only asks Graph for unknown users.
+// Concurrent resolutions of the same user are collapsed into one Graph call
+type ScopedIDResolver struct {
+	repo  MetaApiRepository
+	store ScopedIDStore
+
+	group singleflight.Group
+}
+
+// NewScopedIDResolver resolves through repo and remembers in store, an in-memory store when nil
+func NewScopedIDResolver(repo MetaApiRepository, store ScopedIDStore) *ScopedIDResolver {
+	if store == nil {
+		store = NewMemoryScopedIDStore()
+	}
+
+	return &ScopedIDResolver{
+		repo:  repo,
+		store: store,
+	}
+}
+
+// Resolve returns the org scoped ID of appScopedID.
+// When saving a freshly resolved mapping fails, the ID is still returned together with the error
+func (r *ScopedIDResolver) Resolve(ctx context.Context, appScopedID AppScopedID) (orgScopedID OrgScopedID, err error) {
+	var mapping ScopedIDMapping
+	var ok bool
+	if mapping, ok, err = r.store.Lookup(ctx, appScopedID); err != nil || ok {
+		return mapping.OrgScopedID, err
+	}
+
+	v, err := singleflightCtx(ctx, &r.group, string(appScopedID), func(ctx context.Context) (interface{}, error) {
+		resp, err := r.repo.GetOculusOrgScopedIDCtx(ctx, string(appScopedID), GetOculusOrgScopedIDResponseQuery{Fields: Fields(UserFieldOrgScopedID)})
+		if err != nil {
+			return OrgScopedID(""), err
+		}
+
+		resolved := resp.OrgScopedID()
+		if err = r.store.Save(context.WithoutCancel(ctx), ScopedIDMapping{AppScopedID: appScopedID, OrgScopedID: resolved, ResolvedAt: time.Now()}); err != nil {
+			err = fmt.Errorf("metaapiwrapper: save scoped id mapping: %w", err)
+		}
+		return resolved, err
+	})
+
+	orgScopedID, _ = v.(OrgScopedID)
+	return
+}
+
+// MemoryScopedIDStore remembers mappings for the lifetime of the process; every instance and restart resolves each user through Graph once
+type MemoryScopedIDStore struct {
+	mu    sync.RWMutex
+	byApp map[AppScopedID]ScopedIDMapping
+	byOrg map[OrgScopedID][]AppScopedID
+}
+
+func NewMemoryScopedIDStore() *MemoryScopedIDStore {
+	return &MemoryScopedIDStore{
+		byApp: map[AppScopedID]ScopedIDMapping{},
+		byOrg: map[OrgScopedID][]AppScopedID{},
+	}
+}
+
+func (s *MemoryScopedIDStore) Lookup(_ context.Context, appScopedID AppScopedID) (mapping ScopedIDMapping, ok bool, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	mapping, ok = s.byApp[appScopedID]
+	return
+}
+
+func (s *MemoryScopedIDStore) LookupOrg(_ context.Context, orgScopedID OrgScopedID) (mappings []ScopedIDMapping, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, appScopedID := range s.byOrg[orgScopedID] {
+		mappings = append(mappings, s.byApp[appScopedID])
+	}
+	return
+}
+
+func (s *MemoryScopedIDStore) Save(_ context.Context, mapping ScopedIDMapping) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.byApp[mapping.AppScopedID]; !ok {
+		s.byOrg[mapping.OrgScopedID] = append(s.byOrg[mapping.OrgScopedID], mapping.AppScopedID)
+	}
+	s.byApp[mapping.AppScopedID] = mapping
+	return nil
+}
+
+// ScopedIDStoreSchema creates the table used by SQLScopedIDStore (MySQL, as opened by dbhelper); %v is the table name
+const ScopedIDStoreSchema = `CREATE TABLE IF NOT EXISTS %v (
+	app_scoped_id VARCHAR(64) NOT NULL PRIMARY KEY,
+	org_scoped_id VARCHAR(64) NOT NULL,
+	resolved_at   BIGINT      NOT NULL,
+	INDEX (org_scoped_id)
+)`
+
+// SQLScopedIDStore persists mappings in a MySQL table, so a user is resolved through Graph once for all instances and restarts
+type SQLScopedIDStore struct {
+	db    *sql.DB
+	table string
+}
+
+// NewSQLScopedIDStore reads and writes mappings in table of db, the connection dbhelper.GetDB() returns in our services;
+// a table that is not a plain [schema.]name is rejected with ErrInvalidTableName
+func NewSQLScopedIDStore(db *sql.DB, table string) (*SQLScopedIDStore, error) {
+	if err := validateTableName(table); err != nil {
+		return nil, err
+	}
+
+	return &SQLScopedIDStore{
+		db:    db,
+		table: table,
+	}, nil
+}
+
+// CreateTable creates the mapping table if it does not exist
+func (s *SQLScopedIDStore) CreateTable(ctx context.Context) (err error) {
+	_, err = s.db.ExecContext(ctx, fmt.Sprintf(ScopedIDStoreSchema, s.table))
+	return
+}
+
+func (s *SQLScopedIDStore) Lookup(ctx context.Context, appScopedID AppScopedID) (mapping ScopedIDMapping, ok bool, err error) {
+	var resolvedAt int64
+	err = s.db.QueryRowContext(ctx,
+		fmt.Sprintf("SELECT app_scoped_id, org_scoped_id, resolved_at FROM %v WHERE app_scoped_id = ?", s.table),
+		appScopedID).Scan(&mapping.AppScopedID, &mapping.OrgScopedID, &resolvedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return mapping, false, nil
+	}
+	if err != nil {
+		return
+	}
+
+	mapping.ResolvedAt = time.UnixMilli(resolvedAt)
+	return mapping, true, nil
+}
+
+func (s *SQLScopedIDStore) LookupOrg(ctx context.Context, orgScopedID OrgScopedID) (mappings []ScopedIDMapping, err error) {
+	var rows *sql.Rows
+	if rows, err = s.db.QueryContext(ctx,
+		fmt.Sprintf("SELECT app_scoped_id, org_scoped_id, resolved_at FROM %v WHERE org_scoped_id = ?", s.table),
+		orgScopedID); err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var mapping ScopedIDMapping
+		var resolvedAt int64
+		if err = rows.Scan(&mapping.AppScopedID, &mapping.OrgScopedID, &resolvedAt); err != nil {
+			return
+		}
+		mapping.ResolvedAt = time.UnixMilli(resolvedAt)
+		mappings = append(mappings, mapping)
+	}
+	err = rows.Err()
+	return
+}
+
+func (s *SQLScopedIDStore) Save(ctx context.Context, mapping ScopedIDMapping) (err error) {
+	_, err = s.db.ExecContext(ctx,
+		fmt.Sprintf("INSERT INTO %v (app_scoped_id, org_scoped_id, resolved_at) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE org_scoped_id = VALUES(org_scoped_id), resolved_at = VALUES(resolved_at)", s.table),
+		mapping.AppScopedID, mapping.OrgScopedID, mapping.ResolvedAt.UnixMilli())
+	return
+}
@@ -0,0 +1,95 @@
+package metaapiwrapper_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hyperbting/api-library/pkg/metaapiwrapper"
+	"github.com/hyperbting/api-library/pkg/metaapiwrapper/metaapitest"
+)
+
+func TestScopedIDResolverCaches(t *testing.T) {
+	s := metaapitest.NewServer("app", "secret")
+	defer s.Close()
+	s.AddUser(metaapitest.User{ID: "u1", OrgScopedID: "org1"})
+
+	store := metaapiwrapper.NewMemoryScopedIDStore()
+	resolver := metaapiwrapper.NewScopedIDResolver(metaapiwrapper.NewMetaApiRepository(s.Options()...), store)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if got, err := resolver.Resolve(ctx, "u1"); err != nil || got != "org1" {
+			t.Fatalf("resolve %v: %q, %v", i, got, err)
+		}
+	}
+	if n := s.Calls(metaapitest.EndpointOrgScopedID); n != 1 {
+		t.Errorf("%v Graph calls, want 1", n)
+	}
+
+	if mappings, err := store.LookupOrg(ctx, "org1"); err != nil || len(mappings) != 1 || mappings[0].AppScopedID != "u1" {
+		t.Errorf("LookupOrg: %+v, %v", mappings, err)
+	}
+}
+
+func TestScopedIDResolverCallerCancelled(t *testing.T) {
+	// the first call hangs until its client goes away, later ones answer at once
+	started := make(chan struct{})
+	var calls atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			close(started)
+			<-r.Context().Done()
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"org1","org_scoped_id":"u1"}`))
+	}))
+	defer s.Close()
+
+	resolver := metaapiwrapper.NewScopedIDResolver(metaapiwrapper.NewMetaApiRepository(
+		metaapiwrapper.WithBaseURL(s.URL),
+		metaapiwrapper.WithPlatformConfig(metaapiwrapper.OCULUSPlatformConfig{AppID: "app", AppSecret: "secret"}),
+	), nil)
+
+	firstCtx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := resolver.Resolve(firstCtx, "u1")
+		first <- err
+	}()
+	<-started
+
+	second := make(chan error, 1)
+	go func() {
+		got, err := resolver.Resolve(context.Background(), "u1")
+		if err == nil && got != "org1" {
+			err = errors.New("resolved " + string(got))
+		}
+		second <- err
+	}()
+
+	// give the second caller time to join the flight of the first
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("first caller: got %v, want context.Canceled", err)
+	}
+	if err := <-second; err != nil {
+		t.Errorf("second caller: %v", err)
+	}
+}
+
+func TestSQLScopedIDStoreTableName(t *testing.T) {
+	if _, err := metaapiwrapper.NewSQLScopedIDStore(nil, "app.scoped_ids"); err != nil {
+		t.Error(err)
+	}
+	if _, err := metaapiwrapper.NewSQLScopedIDStore(nil, "ids; DROP TABLE users"); !errors.Is(err, metaapiwrapper.ErrInvalidTableName) {
+		t.Errorf("got %v, want ErrInvalidTableName", err)
+	}
+}
@@ -0,0 +1,121 @@
+package metaapiwrapper
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// SignatureHeader carries the "sha256=<hex>" signature of the request body
+	SignatureHeader = "X-Hub-Signature-256"
+
+	defaultMaxSignedBodyBytes = 1 << 20
+)
+
+// SignatureVerifier is satisfied by MetaApiRepository
+type SignatureVerifier interface {
+	VerifySHA256Signature(payload string, header string) bool
+}
+
+// SignatureMiddlewareConfig tunes SignatureMiddleware; the zero value is usable
+type SignatureMiddlewareConfig struct {
+	// Header holding the signature, SignatureHeader when empty
+	Header string
+	// MaxBodyBytes read for verification, 1MiB when 0
+	MaxBodyBytes int64
+	// ReplayWindow rejects a signed body seen again within the window; 0 disables replay protection
+	ReplayWindow time.Duration
+}
+
+// SignatureMiddleware rejects requests whose body does not match the signature header with 401,
+// and replays of an already accepted body with 409, before they reach next.
+// The body is buffered and handed to next unchanged
+func SignatureMiddleware(verifier SignatureVerifier, cfg SignatureMiddlewareConfig) func(next http.Handler) http.Handler {
+	header := cfg.Header
+	if len(header) <= 0 {
+		header = SignatureHeader
+	}
+
+	maxBodyBytes := cfg.MaxBodyBytes
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = defaultMaxSignedBodyBytes
+	}
+
+	var replays *replayGuard
+	if cfg.ReplayWindow > 0 {
+		replays = newReplayGuard(cfg.ReplayWindow)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					http.Error(w, "payload too large", http.StatusRequestEntityTooLarge)
+					return
+				}
+				http.Error(w, "cannot read payload", http.StatusBadRequest)
+				return
+			}
+
+			signature := r.Header.Get(header)
+			if !verifier.VerifySHA256Signature(string(body), signature) {
+				http.Error(w, "invalid signature", http.StatusUnauthorized)
+				return
+			}
+
+			// only verified payloads are remembered, so forged requests cannot fill the guard
+			if replays != nil && !replays.firstSeen(body) {
+				http.Error(w, "replayed payload", http.StatusConflict)
+				return
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// replayGuard remembers signed payloads for a window
+type replayGuard struct {
+	window time.Duration
+
+	mu   sync.Mutex
+	seen map[[sha256.Size]byte]time.Time
+}
+
+func newReplayGuard(window time.Duration) *replayGuard {
+	return &replayGuard{
+		window: window,
+		seen:   map[[sha256.Size]byte]time.Time{},
+	}
+}
+
+// firstSeen records body and reports whether it was new within the window.
+// The signature is left out of the key: it is derived from the body, and its hex case or prefix can vary between replays
+func (g *replayGuard) firstSeen(body []byte) bool {
+	key := sha256.Sum256(body)
+	now := time.Now()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for k, expiry := range g.seen {
+		if now.After(expiry) {
+			delete(g.seen, k)
+		}
+	}
+
+	if _, ok := g.seen[key]; ok {
+		return false
+	}
+
+	g.seen[key] = now.Add(g.window)
+	return true
+}
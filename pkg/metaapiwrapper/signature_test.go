@@ -0,0 +1,64 @@
+package metaapiwrapper_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperbting/api-library/pkg/metaapiwrapper"
+)
+
+func sign(secret, body string) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(h.Sum(nil))
+}
+
+func TestSignatureMiddleware(t *testing.T) {
+	repo := metaapiwrapper.NewMetaApiRepository(metaapiwrapper.WithPlatformConfig(metaapiwrapper.OCULUSPlatformConfig{AppID: "app", AppSecret: "secret"}))
+
+	var served int
+	handler := metaapiwrapper.SignatureMiddleware(repo, metaapiwrapper.SignatureMiddlewareConfig{ReplayWindow: time.Minute})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			served++
+		}))
+
+	post := func(body, signature string) int {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		r.Header.Set(metaapiwrapper.SignatureHeader, signature)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	signature := sign("secret", `{"event":"a"}`)
+	upper := "sha256=" + strings.ToUpper(strings.TrimPrefix(signature, "sha256="))
+
+	tests := []struct {
+		name      string
+		body      string
+		signature string
+		want      int
+	}{
+		{"valid", `{"event":"a"}`, signature, http.StatusOK},
+		{"replay", `{"event":"a"}`, signature, http.StatusConflict},
+		{"replay with upper case signature", `{"event":"a"}`, upper, http.StatusConflict},
+		{"wrong secret", `{"event":"b"}`, sign("other", `{"event":"b"}`), http.StatusUnauthorized},
+		{"missing prefix", `{"event":"b"}`, strings.TrimPrefix(sign("secret", `{"event":"b"}`), "sha256="), http.StatusUnauthorized},
+		{"other body", `{"event":"b"}`, sign("secret", `{"event":"b"}`), http.StatusOK},
+	}
+	for _, tt := range tests {
+		if got := post(tt.body, tt.signature); got != tt.want {
+			t.Errorf("%v: status %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if served != 2 {
+		t.Errorf("handler served %v requests, want 2", served)
+	}
+}
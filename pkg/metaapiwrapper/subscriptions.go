@@ -0,0 +1,194 @@
+package metaapiwrapper
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+type SubscriptionStatus string
+
+const (
+	SubscriptionActive    SubscriptionStatus = "ACTIVE"
+	SubscriptionCancelled SubscriptionStatus = "CANCELLED"
+	SubscriptionExpired   SubscriptionStatus = "EXPIRED"
+	SubscriptionRefunded  SubscriptionStatus = "REFUNDED"
+)
+
+// subscription fields, decoded into OculusSubscription
+const (
+	SubscriptionFieldID              Field = "id"
+	SubscriptionFieldSKU             Field = "sku"
+	SubscriptionFieldStatus          Field = "status"
+	SubscriptionFieldIsActive        Field = "is_active"
+	SubscriptionFieldIsTrial         Field = "is_trial"
+	SubscriptionFieldIsAutoRenewing  Field = "is_auto_renewing"
+	SubscriptionFieldPeriodStartTime Field = "period_start_time"
+	SubscriptionFieldPeriodEndTime   Field = "period_end_time"
+	SubscriptionFieldNextRenewalTime Field = "next_renewal_time"
+)
+
+// DefaultSubscriptionFields is requested when SubscriptionsQuery.Fields is empty; Graph would otherwise return the id only
+var DefaultSubscriptionFields = []Field{
+	SubscriptionFieldID,
+	SubscriptionFieldSKU,
+	SubscriptionFieldStatus,
+	SubscriptionFieldIsActive,
+	SubscriptionFieldIsTrial,
+	SubscriptionFieldIsAutoRenewing,
+	SubscriptionFieldPeriodStartTime,
+	SubscriptionFieldPeriodEndTime,
+	SubscriptionFieldNextRenewalTime,
+}
+
+type SubscriptionsQuery struct {
+	UserID string `json:"user_id"`
+	// SKUs limits the result, all subscriptions of the user when empty
+	SKUs []string `json:"skus,omitempty"`
+	// Fields selects what is decoded into OculusSubscription; DefaultSubscriptionFields when empty
+	Fields []string `json:"fields"`
+	After  string   `json:"after,omitempty"`
+}
+
+func (s *SubscriptionsQuery) BuildQuery(cfg OCULUSPlatformConfig) url.Values {
+	params := url.Values{}
+	params.Add("access_token", cfg.FormAccessToken())
+	params.Add("user_id", s.UserID)
+	if len(s.SKUs) > 0 {
+		params.Add("skus", jsonArrayParam(s.SKUs))
+	}
+	params.Add("fields", strings.Join(fieldsOrDefault(s.Fields, DefaultSubscriptionFields), ","))
+	if len(s.After) > 0 {
+		params.Add("after", s.After)
+	}
+
+	return params
+}
+
+// OculusSubscription is the raw Graph record, times in unix seconds
+type OculusSubscription struct {
+	ID              string             `json:"id"`
+	SKU             string             `json:"sku"`
+	Status          SubscriptionStatus `json:"status"`
+	IsActive        bool               `json:"is_active"`
+	IsTrial         bool               `json:"is_trial"`
+	AutoRenew       bool               `json:"is_auto_renewing"`
+	PeriodStartTime int64              `json:"period_start_time"`
+	PeriodEndTime   int64              `json:"period_end_time"`
+	NextRenewalTime int64              `json:"next_renewal_time"`
+}
+
+type SubscriptionsResponse struct {
+	Data   []OculusSubscription `json:"data"`
+	Paging OculusPaging         `json:"paging"`
+}
+
+func (m *metaApiRepositoryImpl) RequestOculusSubscriptions(q SubscriptionsQuery) (oculusResp SubscriptionsResponse, err error) {
+	return m.RequestOculusSubscriptionsCtx(context.Background(), q)
+}
+
+// RequestOculusSubscriptionsCtx returns one page of the subscriptions of q.UserID
+func (m *metaApiRepositoryImpl) RequestOculusSubscriptionsCtx(ctx context.Context, q SubscriptionsQuery) (oculusResp SubscriptionsResponse, err error) {
+	var req *http.Request
+
+	if req, err = m.newEndpointRequest(ctx, EndpointSubscriptions, "", q.BuildQuery(m.AccessToken)); err != nil {
+		return
+	}
+
+	// Send request
+	err = m.call(EndpointSubscriptions, req, &oculusResp, true)
+
+	return
+}
+
+// Subscription is OculusSubscription with real times
+type Subscription struct {
+	ID        string
+	SKU       string
+	Status    SubscriptionStatus
+	IsActive  bool
+	IsTrial   bool
+	AutoRenew bool
+
+	PeriodStart time.Time
+	PeriodEnd   time.Time
+	// NextRenewal is zero when the subscription does not renew
+	NextRenewal time.Time
+}
+
+func (o OculusSubscription) Subscription() Subscription {
+	return Subscription{
+		ID:          o.ID,
+		SKU:         o.SKU,
+		Status:      o.Status,
+		IsActive:    o.IsActive,
+		IsTrial:     o.IsTrial,
+		AutoRenew:   o.AutoRenew,
+		PeriodStart: unixTime(o.PeriodStartTime),
+		PeriodEnd:   unixTime(o.PeriodEndTime),
+		NextRenewal: unixTime(o.NextRenewalTime),
+	}
+}
+
+// Subscriptions converts every record of the page
+func (r SubscriptionsResponse) Subscriptions() []Subscription {
+	subs := make([]Subscription, 0, len(r.Data))
+	for _, o := range r.Data {
+		subs = append(subs, o.Subscription())
+	}
+	return subs
+}
+
+// InPeriod reports whether now falls in the paid (or trial) period
+func (s Subscription) InPeriod(now time.Time) bool {
+	return !now.Before(s.PeriodStart) && now.Before(s.PeriodEnd)
+}
+
+// RenewsIn is the time left until the next renewal; renews is false when the subscription will not renew
+func (s Subscription) RenewsIn(now time.Time) (left time.Duration, renews bool) {
+	if !s.AutoRenew || s.NextRenewal.IsZero() {
+		return 0, false
+	}
+	return s.NextRenewal.Sub(now), true
+}
+
+// HasPremiumAccess reports whether s entitles to premium content at now.
+// Refunded subscriptions never do; a cancelled one keeps access until the period ends;
+// an auto renewing one that Graph still reports ACTIVE and is_active keeps access for grace past the period end
+// while the renewal is processed. Expired ones and failed renewals get no grace
+func (s Subscription) HasPremiumAccess(now time.Time, grace time.Duration) bool {
+	switch {
+	case s.Status == SubscriptionRefunded:
+		return false
+	case s.InPeriod(now):
+		return true
+	case s.AutoRenew && s.Status == SubscriptionActive && s.IsActive && !now.Before(s.PeriodEnd):
+		return now.Before(s.PeriodEnd.Add(grace))
+	default:
+		return false
+	}
+}
+
+// PremiumPolicy decides premium entitlement from a user's subscriptions
+type PremiumPolicy struct {
+	// SKUs granting premium, any subscription when empty
+	SKUs []string
+	// GracePeriod keeps access after a period ends while an auto renewal is pending
+	GracePeriod time.Duration
+}
+
+// Entitled reports whether any subscription of subs grants premium at now
+func (p PremiumPolicy) Entitled(subs []Subscription, now time.Time) bool {
+	for _, s := range subs {
+		if len(p.SKUs) > 0 && !slices.Contains(p.SKUs, s.SKU) {
+			continue
+		}
+		if s.HasPremiumAccess(now, p.GracePeriod) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package metaapiwrapper_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperbting/api-library/pkg/metaapiwrapper"
+)
+
+func TestSubscriptionsDefaultFields(t *testing.T) {
+	// Graph returns the id only unless fields are selected
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if !strings.Contains(r.FormValue("fields"), "period_end_time") {
+			w.Write([]byte(`{"data":[{"id":"sub1"}]}`))
+			return
+		}
+		w.Write([]byte(`{"data":[{"id":"sub1","sku":"premium","status":"ACTIVE","is_auto_renewing":true,"period_start_time":1000,"period_end_time":2000000000}]}`))
+	}))
+	defer s.Close()
+
+	repo := metaapiwrapper.NewMetaApiRepository(
+		metaapiwrapper.WithBaseURL(s.URL),
+		metaapiwrapper.WithPlatformConfig(metaapiwrapper.OCULUSPlatformConfig{AppID: "app", AppSecret: "secret"}),
+	)
+
+	resp, err := repo.RequestOculusSubscriptionsCtx(context.Background(), metaapiwrapper.SubscriptionsQuery{UserID: "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policy := metaapiwrapper.PremiumPolicy{SKUs: []string{"premium"}}
+	if !policy.Entitled(resp.Subscriptions(), time.Unix(1500000000, 0)) {
+		t.Errorf("subscriber not entitled: %+v", resp.Subscriptions())
+	}
+}
+
+func TestSubscriptionFromGraph(t *testing.T) {
+	var resp metaapiwrapper.SubscriptionsResponse
+	if err := json.Unmarshal([]byte(`{"data":[
+		{"id":"s1","sku":"premium","status":"ACTIVE","is_active":true,"is_auto_renewing":true,"period_start_time":1000,"period_end_time":2000},
+		{"id":"s2","sku":"premium","status":"EXPIRED","is_active":false,"is_auto_renewing":true,"period_start_time":1000,"period_end_time":2000}
+	]}`), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	subs := resp.Subscriptions()
+	if !subs[0].IsActive || subs[1].IsActive {
+		t.Fatalf("is_active not carried over: %+v", subs)
+	}
+
+	// an hour past the period end, inside the grace period
+	now, grace := time.Unix(2000+3600, 0), 72*time.Hour
+	if !subs[0].HasPremiumAccess(now, grace) {
+		t.Error("active subscription pending renewal lost access")
+	}
+	if subs[1].HasPremiumAccess(now, grace) {
+		t.Error("expired subscription kept access")
+	}
+	if (metaapiwrapper.PremiumPolicy{GracePeriod: grace}).Entitled(subs[1:], now) {
+		t.Error("policy entitles an expired subscription")
+	}
+}
+
+func TestSubscriptionHasPremiumAccess(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+	grace := 72 * time.Hour
+
+	sub := func(status metaapiwrapper.SubscriptionStatus, autoRenew bool) metaapiwrapper.Subscription {
+		return metaapiwrapper.Subscription{SKU: "premium", Status: status, IsActive: status == metaapiwrapper.SubscriptionActive, AutoRenew: autoRenew, PeriodStart: start, PeriodEnd: end}
+	}
+	// a renewal Graph already gave up on: still ACTIVE, no longer is_active
+	failedRenewal := sub(metaapiwrapper.SubscriptionActive, true)
+	failedRenewal.IsActive = false
+
+	tests := []struct {
+		name string
+		sub  metaapiwrapper.Subscription
+		now  time.Time
+		want bool
+	}{
+		{"in period", sub(metaapiwrapper.SubscriptionActive, true), start.Add(time.Hour), true},
+		{"before period", sub(metaapiwrapper.SubscriptionActive, true), start.Add(-time.Hour), false},
+		{"cancelled keeps the paid period", sub(metaapiwrapper.SubscriptionCancelled, false), end.Add(-time.Hour), true},
+		{"cancelled gets no grace", sub(metaapiwrapper.SubscriptionCancelled, false), end.Add(time.Hour), false},
+		{"renewal pending within grace", sub(metaapiwrapper.SubscriptionActive, true), end.Add(time.Hour), true},
+		{"renewal pending past grace", sub(metaapiwrapper.SubscriptionActive, true), end.Add(grace + time.Hour), false},
+		{"refunded", sub(metaapiwrapper.SubscriptionRefunded, true), start.Add(time.Hour), false},
+		{"expired gets no grace", sub(metaapiwrapper.SubscriptionExpired, true), end.Add(time.Hour), false},
+		{"failed renewal gets no grace", failedRenewal, end.Add(time.Hour), false},
+		{"failed renewal keeps the paid period", failedRenewal, end.Add(-time.Hour), true},
+	}
+	for _, tt := range tests {
+		if got := tt.sub.HasPremiumAccess(tt.now, grace); got != tt.want {
+			t.Errorf("%v: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -1,27 +1,39 @@
 package metaapiwrapper
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"gorm.io/gorm"
 	"io"
-	"log"
+	"iter"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
 )
 
+// Graph path components; full paths are assembled in one place, see graphEndpoints
 const (
 	UserNonceValidateUrl   = "/user_nonce_validate"
 	VerifyItemOwnershipUrl = "/verify_entitlement"
 	ConsumeIAPItemUrl      = "/consume_entitlement"
 	RetrieveItemsOwnedUrl  = "/viewer_purchases"
 
+	AchievementDefinitionsUrl = "/achievement_definitions"
+	UserAchievementsUrl       = "/achievements"
+
+	LeaderboardsUrl           = "/leaderboards"
+	LeaderboardSubmitEntryUrl = "/leaderboard_submit_entry"
+	LeaderboardEntriesUrl     = "/leaderboard_entries"
+	LeaderboardRemoveEntryUrl = "/leaderboard_remove_entry"
+
+	SubscriptionsUrl = "/subscriptions"
+
 	OculusPlatformServer = "https://graph.oculus.com"
 )
 
@@ -31,18 +43,186 @@ var (
 
 type MetaApiRepository interface {
 	GenerateSHA256SignatureWithOculusSecret(devPayload string) string
+	VerifySHA256Signature(payload string, header string) bool
 	GetOculusOrgScopedID(oculusUsrID string, q GetOculusOrgScopedIDResponseQuery) (respOrgScopedID GetOculusOrgScopedIDResponse, err error)
 	RequestOculusUserNonceValidate(q UserNonceValidateQuery) (OculusResp UserNonceValidateResponse, err error)
 	RequestOculusRetrieveItemsOwned(q RetrieveItemsOwnedQuery) (oculusResp RetrieveItemsOwnedResponse, err error)
 	RequestOculusVerifyItemOwnership(q VerifyItemOwnershipQuery) (OculusResp OCULUSResponseBase, err error)
-}
+	RequestOculusConsumeIAPItem(q OculusConsumeIAPItemQuery) (OculusResp OCULUSResponseBase, err error)
+
+	// context aware variants; cancellation, deadlines and values of ctx are carried to the Graph call
+	GetOculusOrgScopedIDCtx(ctx context.Context, oculusUsrID string, q GetOculusOrgScopedIDResponseQuery) (respOrgScopedID GetOculusOrgScopedIDResponse, err error)
+	RequestOculusUserNonceValidateCtx(ctx context.Context, q UserNonceValidateQuery) (OculusResp UserNonceValidateResponse, err error)
+	RequestOculusRetrieveItemsOwnedCtx(ctx context.Context, q RetrieveItemsOwnedQuery) (oculusResp RetrieveItemsOwnedResponse, err error)
+	RequestOculusVerifyItemOwnershipCtx(ctx context.Context, q VerifyItemOwnershipQuery) (OculusResp OCULUSResponseBase, err error)
+	RequestOculusConsumeIAPItemCtx(ctx context.Context, q OculusConsumeIAPItemQuery) (OculusResp OCULUSResponseBase, err error)
+
+	// viewer_purchases across every page
+	IterItemsOwned(ctx context.Context, q RetrieveItemsOwnedQuery) iter.Seq2[OculusData, error]
+	AllItemsOwned(ctx context.Context, q RetrieveItemsOwnedQuery) (items []OculusData, err error)
+
+	// ownership of many SKUs at once, keyed by SKU (and user)
+	VerifyOwnershipBatch(ctx context.Context, userID string, skus []string) map[string]OwnershipResult
+	VerifyOwnershipBatchUsers(ctx context.Context, userIDs []string, skus []string) map[string]map[string]OwnershipResult
+
+	// achievements, server to server
+	RequestOculusAchievementDefinitions(q AchievementDefinitionsQuery) (oculusResp AchievementDefinitionsResponse, err error)
+	RequestOculusAchievementDefinitionsCtx(ctx context.Context, q AchievementDefinitionsQuery) (oculusResp AchievementDefinitionsResponse, err error)
+	RequestOculusUserAchievements(q UserAchievementsQuery) (oculusResp UserAchievementsResponse, err error)
+	RequestOculusUserAchievementsCtx(ctx context.Context, q UserAchievementsQuery) (oculusResp UserAchievementsResponse, err error)
+	RequestOculusWriteAchievement(q WriteAchievementQuery) (oculusResp WriteAchievementResponse, err error)
+	RequestOculusWriteAchievementCtx(ctx context.Context, q WriteAchievementQuery) (oculusResp WriteAchievementResponse, err error)
+
+	// leaderboards, server to server
+	RequestOculusLeaderboards(q LeaderboardsQuery) (oculusResp LeaderboardsResponse, err error)
+	RequestOculusLeaderboardsCtx(ctx context.Context, q LeaderboardsQuery) (oculusResp LeaderboardsResponse, err error)
+	RequestOculusSubmitLeaderboardEntry(q SubmitLeaderboardEntryQuery) (oculusResp SubmitLeaderboardEntryResponse, err error)
+	RequestOculusSubmitLeaderboardEntryCtx(ctx context.Context, q SubmitLeaderboardEntryQuery) (oculusResp SubmitLeaderboardEntryResponse, err error)
+	RequestOculusLeaderboardEntries(q LeaderboardEntriesQuery) (oculusResp LeaderboardEntriesResponse, err error)
+	RequestOculusLeaderboardEntriesCtx(ctx context.Context, q LeaderboardEntriesQuery) (oculusResp LeaderboardEntriesResponse, err error)
+	RequestOculusRemoveLeaderboardEntry(q RemoveLeaderboardEntryQuery) (OculusResp OCULUSResponseBase, err error)
+	RequestOculusRemoveLeaderboardEntryCtx(ctx context.Context, q RemoveLeaderboardEntryQuery) (OculusResp OCULUSResponseBase, err error)
+
+	// subscriptions of a user
+	RequestOculusSubscriptions(q SubscriptionsQuery) (oculusResp SubscriptionsResponse, err error)
+	RequestOculusSubscriptionsCtx(ctx context.Context, q SubscriptionsQuery) (oculusResp SubscriptionsResponse, err error)
+}
+
+func NewMetaApiRepository(opts ...Option) MetaApiRepository {
+	m := &metaApiRepositoryImpl{
+		baseURL: OculusPlatformServer,
+		logger:  discardLogger,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	m.client = m.buildHttpClient()
 
-func NewMetaApiRepository() MetaApiRepository {
-	return &metaApiRepositoryImpl{}
+	return m
 }
 
 type metaApiRepositoryImpl struct {
 	AccessToken OCULUSPlatformConfig
+
+	baseURL   string
+	userAgent string
+
+	// optional Graph API version prefix, see WithAPIVersion
+	apiVersion string
+
+	// http.Client settings collected from Option, see buildHttpClient
+	httpClient *http.Client
+	transport  http.RoundTripper
+	timeout    time.Duration
+
+	client *http.Client
+
+	// retry of idempotent calls, disabled when zero
+	retry RetryPolicy
+
+	// silent unless set by WithLogger
+	logger *slog.Logger
+
+	batch BatchOptions
+
+	// optional client side throttling, shared between repositories of the same app
+	limiter *AppRateLimiter
+
+	// no-op unless set by WithInstrumentation
+	instrumentation Instrumentation
+
+	// optional, makes RequestOculusConsumeIAPItem idempotent per OculusConsumeIAPItemQuery.IdempotencyKey
+	ledger           ConsumptionLedger
+	ledgerPendingTTL time.Duration
+
+	// optional per endpoint circuit breakers, and what verify_entitlement answers while open
+	breakers          *circuitBreakers
+	ownershipFallback OwnershipFallback
+}
+
+// newRequest builds a request against the configured Graph server
+func (m *metaApiRepositoryImpl) newRequest(ctx context.Context, method, path string, params url.Values) (req *http.Request, err error) {
+	baseURL := m.baseURL
+	if len(baseURL) <= 0 {
+		baseURL = OculusPlatformServer
+	}
+
+	if req, err = http.NewRequestWithContext(ctx, method, fmt.Sprintf("%v%v?%v", baseURL, path, params.Encode()), nil); err != nil {
+		return
+	}
+
+	if len(m.userAgent) > 0 {
+		req.Header.Set("User-Agent", m.userAgent)
+	}
+	return
+}
+
+// do sends req with the shared client and returns the HTTP status and whole response body.
+// Graph errors are returned as *MetaAPIError together with the body they were read from
+func (m *metaApiRepositoryImpl) do(req *http.Request) (status int, respBytes []byte, err error) {
+	client := m.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	logger := m.logger
+	if logger == nil {
+		logger = discardLogger
+	}
+
+	ctx := req.Context()
+	logger.DebugContext(ctx, "metaapiwrapper request", "method", req.Method, "url", redactURL(req.URL))
+
+	if m.limiter != nil {
+		if err = m.limiter.Wait(ctx, m.AccessToken.AppID); err != nil {
+			return
+		}
+	}
+
+	var resp *http.Response
+	if resp, err = client.Do(req); err != nil {
+		// the error carries the request URL, keep the access token out of whoever logs or traces it
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = redactURL(req.URL)
+		}
+		logger.WarnContext(ctx, "metaapiwrapper request failed", "method", req.Method, "url", redactURL(req.URL), "error", err)
+		return
+	}
+
+	defer resp.Body.Close()
+
+	status = resp.StatusCode
+	if respBytes, err = io.ReadAll(resp.Body); err != nil {
+		return
+	}
+
+	if logger.Enabled(ctx, slog.LevelDebug) {
+		logger.DebugContext(ctx, "metaapiwrapper response", "url", redactURL(req.URL), "status", resp.StatusCode, "body", RedactAccessToken(string(respBytes)))
+	}
+
+	if apiErr := parseMetaAPIError(resp, respBytes); apiErr != nil {
+		logger.WarnContext(ctx, "metaapiwrapper graph error", "url", redactURL(req.URL), "status", resp.StatusCode, "code", apiErr.Code, "error_subcode", apiErr.ErrorSubcode, "fbtrace_id", apiErr.FBTraceID)
+		err = apiErr
+	}
+	return
+}
+
+// doJSON sends req and decodes the response into out.
+// out is decoded even on *MetaAPIError so that the legacy Error fields of responses stay populated
+func (m *metaApiRepositoryImpl) doJSON(req *http.Request, out interface{}) (status int, err error) {
+	var respBytes []byte
+	status, respBytes, err = m.do(req)
+
+	var apiErr *MetaAPIError
+	if err != nil && !errors.As(err, &apiErr) {
+		return
+	}
+
+	if jsonErr := json.Unmarshal(respBytes, out); err == nil {
+		err = jsonErr
+	}
+	return
 }
 
 type OCULUSPlatformConfig struct {
@@ -52,10 +232,17 @@ type OCULUSPlatformConfig struct {
 
 func (c *OCULUSPlatformConfig) FormAccessToken() (oculusPlatformAccessToken string) {
 	oculusPlatformAccessToken = fmt.Sprintf("OC|%v|%v", c.AppID, c.AppSecret)
-	log.Printf("FormAccessToken using %v %v: %v", c.AppID, c.AppSecret, oculusPlatformAccessToken)
 	return
 }
 
+// LogValue keeps AppSecret out of slog output
+func (c OCULUSPlatformConfig) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("app_id", c.AppID),
+		slog.String("app_secret", redacted),
+	)
+}
+
 type OCULUSResponseError struct {
 	Message string `json:"message,omitempty"`
 	Type    string `json:"type,omitempty"`
@@ -86,49 +273,61 @@ func (v *VerifyItemOwnershipQuery) BuildQuery(cfg OCULUSPlatformConfig) url.Valu
 	params := url.Values{}
 	params.Add("sku", v.SKU)
 	params.Add("access_token", cfg.FormAccessToken())
+	if len(v.UsrID) > 0 {
+		params.Add("user_id", v.UsrID)
+	}
 
-	log.Println(params)
 	return params
 }
 
 func (m *metaApiRepositoryImpl) RequestOculusVerifyItemOwnership(q VerifyItemOwnershipQuery) (OculusResp OCULUSResponseBase, err error) {
+	return m.RequestOculusVerifyItemOwnershipCtx(context.Background(), q)
+}
+
+func (m *metaApiRepositoryImpl) RequestOculusVerifyItemOwnershipCtx(ctx context.Context, q VerifyItemOwnershipQuery) (OculusResp OCULUSResponseBase, err error) {
 	var req *http.Request
 
 	// OculusPlatformServer+VerifyItemOwnershipUrl+"?"+q.BuildQuery().Encode()
-	if req, err = http.NewRequest("GET", fmt.Sprintf("%v/%v%v?%v", OculusPlatformServer, m.AccessToken.AppID, VerifyItemOwnershipUrl, q.BuildQuery(m.AccessToken).Encode()), nil); err != nil {
+	if req, err = m.newEndpointRequest(ctx, EndpointVerifyEntitlement, "", q.BuildQuery(m.AccessToken)); err != nil {
 		return
 	}
-	log.Printf("RequestOculusVerifyItemOwnership : %v", req.URL)
 
 	// Send request
-	client := http.Client{}
-	var resp *http.Response
-	if resp, err = client.Do(req); err != nil {
-		return
+	if err = m.call(EndpointVerifyEntitlement, req, &OculusResp, true); err != nil {
+		if errors.Is(err, ErrCircuitOpen) && m.ownershipFallback != nil {
+			if owned, ok := m.ownershipFallback.Recall(q.UsrID, q.SKU); ok {
+				OculusResp.Success, err = owned, nil
+			}
+		}
+	} else if m.ownershipFallback != nil {
+		m.ownershipFallback.Remember(q.UsrID, q.SKU, OculusResp.Success)
 	}
 
-	defer resp.Body.Close()
-
-	var respBytes []byte
-	if respBytes, err = io.ReadAll(resp.Body); err != nil {
-		return
+	if err == nil && !OculusResp.Success {
+		err = ErrEntitlementMissing
 	}
-
-	err = json.Unmarshal(respBytes, &OculusResp)
-
 	return
 }
 
 type RetrieveItemsOwnedQuery struct {
-	OrgScopedID string   `json:"user_id"`
-	Fields      []string `json:"fields"`
+	OrgScopedID string `json:"user_id"`
+	// Fields selects what is decoded into OculusData, e.g. Fields(PurchaseFieldID, PurchaseItem(ItemFieldSKU)); DefaultPurchaseFields when empty
+	Fields []string `json:"fields"`
+
+	// After is the paging cursor to continue from, see OculusPaging.Cursors
+	After string `json:"after,omitempty"`
+	// MaxPages guards IterItemsOwned/AllItemsOwned; DefaultMaxItemsOwnedPages when 0
+	MaxPages int `json:"-"`
 }
 
 func (r *RetrieveItemsOwnedQuery) BuildQuery(cfg OCULUSPlatformConfig) url.Values {
 	params := url.Values{}
 	params.Add("access_token", cfg.FormAccessToken()) //params.Add("access_token", r.AccessToken)
 	params.Add("user_id", r.OrgScopedID)
-	params.Add("fields", strings.Join(r.Fields, ","))
+	params.Add("fields", strings.Join(fieldsOrDefault(r.Fields, DefaultPurchaseFields), ","))
+	if len(r.After) > 0 {
+		params.Add("after", r.After)
+	}
 
 	//log.Println(params)
 	return params
@@ -173,36 +372,25 @@ type RetrieveItemsOwnedResponse struct {
 }
 
 func (m *metaApiRepositoryImpl) RequestOculusRetrieveItemsOwned(q RetrieveItemsOwnedQuery) (oculusResp RetrieveItemsOwnedResponse, err error) {
+	return m.RequestOculusRetrieveItemsOwnedCtx(context.Background(), q)
+}
+
+func (m *metaApiRepositoryImpl) RequestOculusRetrieveItemsOwnedCtx(ctx context.Context, q RetrieveItemsOwnedQuery) (oculusResp RetrieveItemsOwnedResponse, err error) {
 	var req *http.Request
 
 	//https://developer.oculus.com/documentation/unity/ps-iap-s2s/
-	// OculusPlatformServer+RetrieveItemsOwnedUrl+"?"+q.BuildQuery().Encode()
-	if req, err = http.NewRequest("GET", fmt.Sprintf("%v/%v%v?%v", OculusPlatformServer, m.AccessToken.AppID, RetrieveItemsOwnedUrl, q.BuildQuery(m.AccessToken).Encode()), nil); err != nil {
-		return
-	}
-	//log.Printf("RequestOculusRetrieveItemsOwned : %v", req.URL)
-
-	// Send request
-	client := http.Client{}
-	var resp *http.Response
-	if resp, err = client.Do(req); err != nil {
+	if err = q.Validate(); err != nil {
 		return
 	}
 
-	defer resp.Body.Close()
-
-	var respBytes []byte
-	if respBytes, err = io.ReadAll(resp.Body); err != nil {
-		return
-	}
-
-	if err = json.Unmarshal(respBytes, &oculusResp); err != nil {
+	// OculusPlatformServer+RetrieveItemsOwnedUrl+"?"+q.BuildQuery().Encode()
+	if req, err = m.newEndpointRequest(ctx, EndpointViewerPurchases, "", q.BuildQuery(m.AccessToken)); err != nil {
 		return
 	}
+	//log.Printf("RequestOculusRetrieveItemsOwned : %v", req.URL)
 
-	if len(oculusResp.Error.Message) > 0 {
-		err = errors.New(oculusResp.Error.Message)
-	}
+	// Send request
+	err = m.call(EndpointViewerPurchases, req, &oculusResp, true)
 
 	return
 }
@@ -223,32 +411,39 @@ func (m *metaApiRepositoryImpl) RequestOculusRetrieveItemsOwned(q RetrieveItemsO
 
 type OculusConsumeIAPItemQuery struct {
 	VerifyItemOwnershipQuery
+
+	// IdempotencyKey identifies the client request; with a ConsumptionLedger configured a retried key is not consumed twice
+	IdempotencyKey string `json:"-"`
 }
 
 func (m *metaApiRepositoryImpl) RequestOculusConsumeIAPItem(q OculusConsumeIAPItemQuery) (OculusResp OCULUSResponseBase, err error) {
+	return m.RequestOculusConsumeIAPItemCtx(context.Background(), q)
+}
+
+// RequestOculusConsumeIAPItemCtx is RequestOculusConsumeIAPItem bound to ctx; consumption is never retried
+func (m *metaApiRepositoryImpl) RequestOculusConsumeIAPItemCtx(ctx context.Context, q OculusConsumeIAPItemQuery) (OculusResp OCULUSResponseBase, err error) {
+	if m.ledger != nil && len(q.IdempotencyKey) > 0 {
+		return m.consumeWithLedger(ctx, q)
+	}
+
+	return m.consumeIAPItem(ctx, q)
+}
+
+func (m *metaApiRepositoryImpl) consumeIAPItem(ctx context.Context, q OculusConsumeIAPItemQuery) (OculusResp OCULUSResponseBase, err error) {
 	var req *http.Request
 
-	if req, err = http.NewRequest("GET", fmt.Sprintf("%v/%v%v?%v", OculusPlatformServer, m.AccessToken.AppID, ConsumeIAPItemUrl, q.BuildQuery(m.AccessToken).Encode()), nil); err != nil {
+	if req, err = m.newEndpointRequest(ctx, EndpointConsumeEntitlement, "", q.BuildQuery(m.AccessToken)); err != nil {
 		return
 	}
-	log.Printf("RequestOculusConsumeIAPItem : %v", req.URL)
 
 	// Send request
-	client := http.Client{}
-	var resp *http.Response
-	if resp, err = client.Do(req); err != nil {
+	if err = m.call(EndpointConsumeEntitlement, req, &OculusResp, false); err != nil {
 		return
 	}
 
-	defer resp.Body.Close()
-
-	var respBytes []byte
-	if respBytes, err = io.ReadAll(resp.Body); err != nil {
-		return
+	if !OculusResp.Success {
+		err = ErrEntitlementMissing
 	}
-
-	err = json.Unmarshal(respBytes, &OculusResp)
-
 	return
 }
 
@@ -272,6 +467,10 @@ func (u *UserNonceValidateQuery) Build(accTkn, uid, nonce string) {
 }
 
 func (u *UserNonceValidateQuery) BuildParameter() string {
+	return u.BuildQuery().Encode()
+}
+
+func (u *UserNonceValidateQuery) BuildQuery() url.Values {
 	parameters := url.Values{}
 
 	// Add parameters to the URL
@@ -279,7 +478,7 @@ func (u *UserNonceValidateQuery) BuildParameter() string {
 	parameters.Add("user_id", u.UserID)
 	parameters.Add("nonce", u.Nonce)
 
-	return parameters.Encode()
+	return parameters
 }
 
 type UserNonceValidateResponse struct {
@@ -288,48 +487,43 @@ type UserNonceValidateResponse struct {
 }
 
 func (m *metaApiRepositoryImpl) RequestOculusUserNonceValidate(q UserNonceValidateQuery) (OculusResp UserNonceValidateResponse, err error) {
-	var req *http.Request
+	return m.RequestOculusUserNonceValidateCtx(context.Background(), q)
+}
 
-	// Combine the base URL, path, and parameters
-	fullURL := fmt.Sprintf("%s%s?%s", OculusPlatformServer, UserNonceValidateUrl, q.BuildParameter())
+// RequestOculusUserNonceValidateCtx is RequestOculusUserNonceValidate bound to ctx; q.RequestTimeout still applies on top of ctx.
+// An empty q.AccessToken is filled with the app access token of the repository
+func (m *metaApiRepositoryImpl) RequestOculusUserNonceValidateCtx(ctx context.Context, q UserNonceValidateQuery) (OculusResp UserNonceValidateResponse, err error) {
+	var req *http.Request
 
-	if req, err = http.NewRequest("POST", fullURL, nil); err != nil {
-		return
+	if len(q.AccessToken) <= 0 {
+		q.AccessToken = m.AccessToken.FormAccessToken()
 	}
-	//log.Printf("RequestOculusUserNonceValidate : %v", req.URL)
 
-	// Send request
-	client := http.Client{}
 	if q.RequestTimeout != 0 {
-		client.Timeout = q.RequestTimeout // Timeout set to 5 seconds by default
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, q.RequestTimeout) // Timeout set to 5 seconds by default
+		defer cancel()
 	}
 
-	var resp *http.Response
-	if resp, err = client.Do(req); err != nil {
-		return
-	}
-
-	defer resp.Body.Close()
-
-	var respBytes []byte
-	if respBytes, err = io.ReadAll(resp.Body); err != nil {
+	if req, err = m.newEndpointRequest(ctx, EndpointUserNonceValidate, "", q.BuildQuery()); err != nil {
 		return
 	}
+	//log.Printf("RequestOculusUserNonceValidate : %v", req.URL)
 
-	//log.Printf("%#v", string(respBytes))
-	if err = json.Unmarshal(respBytes, &OculusResp); err != nil {
-		//log.Println(string(respBytes))
+	// Send request
+	if err = m.call(EndpointUserNonceValidate, req, &OculusResp, true); err != nil {
 		return
 	}
 
 	if !OculusResp.IsValid {
-		err = gorm.ErrRecordNotFound
+		err = ErrNonceInvalid
 	}
 
 	return
 }
 
 type GetOculusOrgScopedIDResponseQuery struct {
+	// Fields selects what is decoded into GetOculusOrgScopedIDResponse; DefaultOrgScopedIDFields when empty
 	Fields []string `json:"fields"`
 	//AccessToken string   `json:"access_token"`
 }
@@ -337,9 +531,8 @@ type GetOculusOrgScopedIDResponseQuery struct {
 func (r *GetOculusOrgScopedIDResponseQuery) BuildQuery(cfg OCULUSPlatformConfig) url.Values {
 	params := url.Values{}
 	params.Add("access_token", cfg.FormAccessToken()) //r.AccessToken)
-	params.Add("fields", strings.Join(r.Fields, ","))
+	params.Add("fields", strings.Join(fieldsOrDefault(r.Fields, DefaultOrgScopedIDFields), ","))
 
-	log.Println(params)
 	return params
 }
 
@@ -358,38 +551,36 @@ func (r *GetOculusOrgScopedIDResponse) IsValid() bool {
 
 // GetOculusOrgScopedID from Oculus usrID to Oculus Verified Org Scoped ID
 // the ID is actually desired value; input oculusUsrID will be in GetOculusOrgScopedIDResponse.ScopedID
+// use the OrgScopedID and AppScopedID accessors, or ScopedIDResolver to avoid a Graph call on every login
 func (m *metaApiRepositoryImpl) GetOculusOrgScopedID(oculusUsrID string, q GetOculusOrgScopedIDResponseQuery) (respOrgScopedID GetOculusOrgScopedIDResponse, err error) {
+	return m.GetOculusOrgScopedIDCtx(context.Background(), oculusUsrID, q)
+}
+
+// GetOculusOrgScopedIDCtx is GetOculusOrgScopedID bound to ctx
+func (m *metaApiRepositoryImpl) GetOculusOrgScopedIDCtx(ctx context.Context, oculusUsrID string, q GetOculusOrgScopedIDResponseQuery) (respOrgScopedID GetOculusOrgScopedIDResponse, err error) {
 	//https://developer.oculus.com/documentation/unity/ps-ownership#retrieve-a-verified-org-scoped-id
 
 	var req *http.Request
 
-	if req, err = http.NewRequest("GET", fmt.Sprintf("%v/%v?%v", OculusPlatformServer, oculusUsrID, q.BuildQuery(m.AccessToken).Encode()), nil); err != nil {
-		return
-	}
-	//log.Printf("GetOculusOrgScopedID : %v", req.URL)
-
-	// Send request
-	client := http.Client{}
-	var resp *http.Response
-	if resp, err = client.Do(req); err != nil {
+	if err = q.Validate(); err != nil {
 		return
 	}
 
-	defer resp.Body.Close()
-
-	var respBytes []byte
-	if respBytes, err = io.ReadAll(resp.Body); err != nil {
+	if req, err = m.newEndpointRequest(ctx, EndpointOrgScopedID, oculusUsrID, q.BuildQuery(m.AccessToken)); err != nil {
 		return
 	}
+	//log.Printf("GetOculusOrgScopedID : %v", req.URL)
 
-	//log.Printf("GetOculusOrgScopedID : %v", string(respBytes))
-
-	if err = json.Unmarshal(respBytes, &respOrgScopedID); err != nil {
+	// Send request
+	if err = m.call(EndpointOrgScopedID, req, &respOrgScopedID, true); err != nil {
+		if IsUserNotFound(err) {
+			err = fmt.Errorf("%w: %w", ErrOrgScopedIDNotFound, err)
+		}
 		return
 	}
 
 	if !respOrgScopedID.IsValid() {
-		err = gorm.ErrRecordNotFound
+		err = ErrOrgScopedIDNotFound
 	}
 	return
 }
@@ -407,3 +598,26 @@ func (m *metaApiRepositoryImpl) GenerateSHA256SignatureWithOculusSecret(devPaylo
 	// Return the signature as a hexadecimal string
 	return "sha256=" + hex.EncodeToString(signature)
 }
+
+// VerifySHA256Signature checks a "sha256=<hex>" header produced by GenerateSHA256SignatureWithOculusSecret against payload, in constant time
+func (m *metaApiRepositoryImpl) VerifySHA256Signature(payload string, header string) bool {
+	// without a secret any signature could be forged
+	if len(m.AccessToken.AppSecret) <= 0 {
+		return false
+	}
+
+	hexSignature, ok := strings.CutPrefix(header, "sha256=")
+	if !ok {
+		return false
+	}
+
+	signature, err := hex.DecodeString(hexSignature)
+	if err != nil {
+		return false
+	}
+
+	h := hmac.New(sha256.New, []byte(m.AccessToken.AppSecret))
+	h.Write([]byte(payload))
+
+	return hmac.Equal(signature, h.Sum(nil))
+}